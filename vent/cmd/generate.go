@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/spf13/cobra"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [abi files]",
+	Short: "Generate an events configuration file from compiled contract ABIs",
+	Long: `Generate a starter events configuration (sqlsol) file with an event definition
+for each event found in solc or burrow deploy ABI outputs (or plain ABI files),
+table names, column names and primary keys are guessed and can be edited afterwards`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runGenerateCmd,
+}
+
+var generateOutFile string
+
+func init() {
+	generateCmd.Flags().StringVar(&generateOutFile, "out-file", generateOutFile, "Generated events configuration file (printed to stdout if not given)")
+
+	ventCmd.AddCommand(generateCmd)
+}
+
+func runGenerateCmd(cmd *cobra.Command, args []string) {
+	eventDefs := []types.EventDefinition{}
+
+	for _, abiFile := range args {
+		byteValue, err := ioutil.ReadFile(abiFile)
+		if err != nil {
+			exitWithError(fmt.Errorf("Error reading ABI file %s: %v", abiFile, err))
+		}
+
+		// contract name defaults to ABI file name
+		contractName := strings.TrimSuffix(filepath.Base(abiFile), filepath.Ext(abiFile))
+
+		fileEventDefs, err := sqlsol.GenerateEventDefinitions(contractName, byteValue)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		eventDefs = append(eventDefs, fileEventDefs...)
+	}
+
+	byteValue, err := json.MarshalIndent(eventDefs, "", "  ")
+	if err != nil {
+		exitWithError(fmt.Errorf("Error encoding events configuration: %v", err))
+	}
+
+	byteValue = append(byteValue, '\n')
+
+	if generateOutFile == "" {
+		os.Stdout.Write(byteValue)
+		return
+	}
+
+	if err := ioutil.WriteFile(generateOutFile, byteValue, 0644); err != nil {
+		exitWithError(fmt.Errorf("Error writing events configuration file %s: %v", generateOutFile, err))
+	}
+}
+
+// exitWithError prints the error and exits with a non zero status
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
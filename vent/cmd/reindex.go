@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuilds event tables into a shadow schema, then swaps it with the schema",
+	Run:   runReindexCmd,
+}
+
+func init() {
+	addConsumerFlags(reindexCmd)
+
+	reindexCmd.Flags().StringVar(&cfg.ShadowSchema, "shadow-schema", cfg.ShadowSchema, "Postgres schema to rebuild event tables into (schema with a _reindex suffix by default)")
+	reindexCmd.Flags().StringVar(&cfg.BackupSchema, "backup-schema", cfg.BackupSchema, "Postgres schema to keep the swapped schema (schema with an _old suffix by default)")
+	reindexCmd.Flags().BoolVar(&cfg.Swap, "swap", cfg.Swap, "Swap the shadow schema with the schema once it has caught up")
+
+	ventCmd.AddCommand(reindexCmd)
+}
+
+func runReindexCmd(cmd *cobra.Command, args []string) {
+	// create the reindexer
+	log := logger.NewLogger(cfg.LogLevel)
+	reindexer := service.NewReindexer(cfg, log)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// run the reindexer until the schemas are swapped
+	if err := reindexer.Run(ctx); err != nil {
+		log.Error("err", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
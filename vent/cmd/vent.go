@@ -1,9 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 
 	"github.com/monax/bosmarmot/vent/config"
@@ -21,51 +21,107 @@ var ventCmd = &cobra.Command{
 var cfg = config.DefaultFlags()
 
 func init() {
-	ventCmd.Flags().StringVar(&cfg.DBAdapter, "db-adapter", cfg.DBAdapter, "Database adatper (only 'postgres' is accepted for now)")
-	ventCmd.Flags().StringVar(&cfg.DBURL, "db-url", cfg.DBURL, "Postgres database URL")
-	ventCmd.Flags().StringVar(&cfg.DBSchema, "db-schema", cfg.DBSchema, "Postgres database schema")
-	ventCmd.Flags().StringVar(&cfg.GRPCAddr, "grpc-addr", cfg.GRPCAddr, "Burrow gRPC address")
-	ventCmd.Flags().StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Logging level (error, warn, info, debug)")
-	ventCmd.Flags().StringVar(&cfg.CfgFile, "cfg-file", cfg.CfgFile, "Event configuration file (full path)")
+	addConsumerFlags(ventCmd)
+
+	ventCmd.Flags().BoolVar(&cfg.Follow, "follow", cfg.Follow, "Keep consuming events of new blocks after reaching the latest one")
+	ventCmd.Flags().StringVar(&cfg.Sink, "sink", cfg.Sink, "Where rows are stored (sql event tables, or jsonl or csv files)")
+	ventCmd.Flags().StringVar(&cfg.FileDir, "file-dir", cfg.FileDir, "Directory of jsonl or csv files (written in a directory named after the schema)")
+	ventCmd.Flags().Int64Var(&cfg.FileMaxSize, "file-max-size", cfg.FileMaxSize, "Size in bytes a file reaches before starting a new file of the table (no limit by default)")
+	ventCmd.Flags().Uint64Var(&cfg.FileHeights, "file-heights", cfg.FileHeights, "Number of block heights of each file of a table (no limit by default)")
+	ventCmd.Flags().StringVar(&cfg.ChainsFile, "chains-file", cfg.ChainsFile, "Chains configuration file (full path), each chain is consumed into its own schema overriding gRPC address, schema and events configuration file")
+}
+
+// addConsumerFlags adds the flags of commands consuming events into the database
+func addConsumerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&cfg.DBAdapter, "db-adapter", cfg.DBAdapter, "Database adatper (only 'postgres' is accepted for now)")
+	cmd.Flags().StringVar(&cfg.DBURL, "db-url", cfg.DBURL, "Postgres database URL")
+	cmd.Flags().StringVar(&cfg.DBSchema, "db-schema", cfg.DBSchema, "Postgres database schema")
+	cmd.Flags().StringVar(&cfg.GRPCAddr, "grpc-addr", cfg.GRPCAddr, "Burrow gRPC address")
+	cmd.Flags().IntVar(&cfg.GRPCMaxRetries, "grpc-max-retries", cfg.GRPCMaxRetries, "Maximum number of consecutive attempts to reconnect to Burrow gRPC events stream")
+	cmd.Flags().DurationVar(&cfg.GRPCRetryBackoff, "grpc-retry-backoff", cfg.GRPCRetryBackoff, "Delay before the first reconnection attempt, doubled on each attempt (up to one minute)")
+	cmd.Flags().StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Logging level (error, warn, info, debug)")
+	cmd.Flags().StringVar(&cfg.CfgFile, "cfg-file", cfg.CfgFile, "Event configuration file (full path)")
+	cmd.Flags().StringSliceVar(&cfg.SystemEvents, "system-events", cfg.SystemEvents, "Non log execution events and transactions stored in system tables (call, input, output, governaccount, tx, block)")
+	cmd.Flags().BoolVar(&cfg.BlockTime, "block-time", cfg.BlockTime, "Store the time of the block of each event in the blocktime column of event tables")
+	cmd.Flags().Uint64Var(&cfg.FromHeight, "from-height", cfg.FromHeight, "First block to consume events from (blocks before the checkpoint are not consumed again)")
+	cmd.Flags().Uint64Var(&cfg.ToHeight, "to-height", cfg.ToHeight, "Last block to consume events from (latest block by default)")
+	cmd.Flags().Uint64Var(&cfg.ConfirmationLag, "confirmation-lag", cfg.ConfirmationLag, "Number of blocks to stay behind the latest block, so only confirmed blocks are consumed")
+	cmd.Flags().DurationVar(&cfg.DrainTimeout, "drain-timeout", cfg.DrainTimeout, "Time given to commit the block in flight on shutdown before rolling it back")
+	cmd.Flags().IntVar(&cfg.CatchUpWorkers, "catch-up-workers", cfg.CatchUpWorkers, "Number of workers fetching and decoding ranges of blocks between the checkpoint and the latest block (blocks are still committed in order)")
+	cmd.Flags().Uint64Var(&cfg.CatchUpRange, "catch-up-range", cfg.CatchUpRange, "Number of blocks of each range fetched by catch-up workers")
+	cmd.Flags().IntVar(&cfg.BatchBlocks, "batch-blocks", cfg.BatchBlocks, "Maximum number of blocks committed in a single database transaction while catching up (blocks are committed one at a time at the latest block)")
+	cmd.Flags().DurationVar(&cfg.BatchTimeout, "batch-timeout", cfg.BatchTimeout, "Maximum time a block is held in a batch of blocks before being committed")
 }
 
 // Execute executes the vent command
 func Execute() {
-	ventCmd.Execute()
+	if err := ventCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// runner is either an events consumer or a consumer of several chains
+type runner interface {
+	Run(ctx context.Context) error
+	Reload()
 }
 
 func runVentCmd(cmd *cobra.Command, args []string) {
-	// create the events consumer
+	// create the events consumer (one for each chain if several are given)
 	log := logger.NewLogger(cfg.LogLevel)
-	consumer := service.NewConsumer(cfg, log)
-
-	// setup channel for termination signals
-	ch := make(chan os.Signal)
 
-	signal.Notify(ch, syscall.SIGTERM)
-	signal.Notify(ch, syscall.SIGINT)
+	var consumer runner = service.NewConsumer(cfg, log)
 
-	// start the events consumer
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	go func() {
-		if err := consumer.Run(); err != nil {
+	if cfg.ChainsFile != "" {
+		chains, err := config.ReadChains(cfg.ChainsFile)
+		if err != nil {
 			log.Error("err", err)
 			os.Exit(1)
 		}
+		consumer = service.NewMultiConsumer(cfg, chains, log)
+	}
 
-		wg.Done()
-	}()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	reloadOnSignal(consumer)
+
+	// run the events consumer until it is done
+	if err := consumer.Run(ctx); err != nil {
+		log.Error("err", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// signalContext returns a context cancelled on termination signals from the OS,
+// so that commands are gracefully shutdown
+func signalContext() (context.Context, context.CancelFunc) {
+	// setup channel for termination signals
+	ch := make(chan os.Signal, 1)
+
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+
+	ctx, cancel := context.WithCancel(context.Background())
 
-	// wait for a termination signal from the OS and
-	// gracefully shutdown the events consumer in that case
 	go func() {
 		<-ch
-		consumer.Shutdown()
+		cancel()
 	}()
 
-	// wait until the events consumer is done
-	wg.Wait()
-	os.Exit(0)
+	return ctx, cancel
+}
+
+// reloadOnSignal requests the events consumer to reload its events config file on SIGHUP
+func reloadOnSignal(consumer runner) {
+	ch := make(chan os.Signal, 1)
+
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		for range ch {
+			consumer.Reload()
+		}
+	}()
 }
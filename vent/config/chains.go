@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Chain contains the configuration of an events source consumed into its own schema,
+// it overrides the corresponding flags
+type Chain struct {
+	Name         string   `json:"name"`
+	GRPCAddr     string   `json:"grpcAddr"`
+	DBSchema     string   `json:"dbSchema"`
+	CfgFile      string   `json:"cfgFile"`
+	SystemEvents []string `json:"systemEvents"`
+}
+
+// chainsConfig is the format of the chains configuration file
+type chainsConfig struct {
+	Chains []Chain `json:"chains"`
+}
+
+// ReadChains reads and validates the chains configuration file
+func ReadChains(chainsFile string) ([]Chain, error) {
+	byteValue, err := ioutil.ReadFile(chainsFile)
+	if err != nil {
+		return nil, fmt.Errorf("ReadChains: %v", err)
+	}
+
+	return ParseChains(byteValue)
+}
+
+// ParseChains parses and validates a chains configuration,
+// chain names and schemas must be unique so each chain has its own checkpoint
+func ParseChains(byteValue []byte) ([]Chain, error) {
+	var cfg chainsConfig
+
+	if err := json.Unmarshal(byteValue, &cfg); err != nil {
+		return nil, fmt.Errorf("ParseChains: %v", err)
+	}
+
+	if len(cfg.Chains) == 0 {
+		return nil, fmt.Errorf("ParseChains: no chains given")
+	}
+
+	names := make(map[string]bool)
+	schemas := make(map[string]bool)
+
+	for _, chain := range cfg.Chains {
+		if chain.Name == "" || chain.GRPCAddr == "" || chain.DBSchema == "" || chain.CfgFile == "" {
+			return nil, fmt.Errorf("ParseChains: name, grpcAddr, dbSchema and cfgFile are required for chain %s", chain.Name)
+		}
+
+		if names[chain.Name] {
+			return nil, fmt.Errorf("ParseChains: duplicated chain name %s", chain.Name)
+		}
+		names[chain.Name] = true
+
+		if schemas[chain.DBSchema] {
+			return nil, fmt.Errorf("ParseChains: chain %s schema %s is used by another chain", chain.Name, chain.DBSchema)
+		}
+		schemas[chain.DBSchema] = true
+	}
+
+	return cfg.Chains, nil
+}
+
+// ForChain returns a copy of the flags with the configuration of a given chain
+func (f *Flags) ForChain(chain Chain) *Flags {
+	cfg := *f
+
+	cfg.GRPCAddr = chain.GRPCAddr
+	cfg.DBSchema = chain.DBSchema
+	cfg.CfgFile = chain.CfgFile
+
+	if chain.SystemEvents != nil {
+		cfg.SystemEvents = chain.SystemEvents
+	}
+
+	return &cfg
+}
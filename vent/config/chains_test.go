@@ -0,0 +1,60 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChains(t *testing.T) {
+	t.Run("successfully parses chains configuration", func(t *testing.T) {
+		chains, err := config.ParseChains([]byte(`{
+			"chains": [
+				{"name": "main", "grpcAddr": "localhost:10997", "dbSchema": "main", "cfgFile": "main.json"},
+				{"name": "test", "grpcAddr": "localhost:20997", "dbSchema": "test", "cfgFile": "test.json", "systemEvents": ["tx"]}
+			]
+		}`))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(chains))
+		require.Equal(t, "localhost:20997", chains[1].GRPCAddr)
+		require.Equal(t, []string{"tx"}, chains[1].SystemEvents)
+	})
+
+	t.Run("returns an error if no chains are given", func(t *testing.T) {
+		_, err := config.ParseChains([]byte(`{"chains": []}`))
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error if a required field is missing", func(t *testing.T) {
+		_, err := config.ParseChains([]byte(`{"chains": [{"name": "main", "grpcAddr": "localhost:10997", "cfgFile": "main.json"}]}`))
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error if chains share a schema", func(t *testing.T) {
+		_, err := config.ParseChains([]byte(`{
+			"chains": [
+				{"name": "main", "grpcAddr": "localhost:10997", "dbSchema": "vent", "cfgFile": "main.json"},
+				{"name": "test", "grpcAddr": "localhost:20997", "dbSchema": "vent", "cfgFile": "test.json"}
+			]
+		}`))
+		require.Error(t, err)
+	})
+}
+
+func TestForChain(t *testing.T) {
+	t.Run("successfully overrides flags with chain configuration", func(t *testing.T) {
+		cfg := config.DefaultFlags()
+		cfg.SystemEvents = []string{"call"}
+
+		chainCfg := cfg.ForChain(config.Chain{Name: "test", GRPCAddr: "localhost:20997", DBSchema: "test", CfgFile: "test.json"})
+		require.Equal(t, "localhost:20997", chainCfg.GRPCAddr)
+		require.Equal(t, "test", chainCfg.DBSchema)
+		require.Equal(t, "test.json", chainCfg.CfgFile)
+		require.Equal(t, []string{"call"}, chainCfg.SystemEvents)
+		require.Equal(t, cfg.DBURL, chainCfg.DBURL)
+
+		// flags are not changed
+		require.Equal(t, "bosmarmot", cfg.DBSchema)
+	})
+}
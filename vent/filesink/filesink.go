@@ -0,0 +1,461 @@
+package filesink
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/pkg/errors"
+)
+
+// defined file formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// CheckpointFile is the file holding the last block completely written (along with the size of each table file)
+const CheckpointFile = "checkpoint.json"
+
+// FileSink writes rows of event tables to files (a JSON Lines or CSV file per table at a time),
+// a new file is started once a file reaches MaxSize bytes or once blocks leave its range of Heights
+// (files are named after the table and the first block written in them)
+type FileSink struct {
+	Dir        string
+	Format     string
+	MaxSize    int64
+	Heights    uint64
+	Log        *logger.Logger
+	files      map[string]*tableFile
+	started    []string
+	checkpoint checkpoint
+}
+
+// checkpoint is the content of the checkpoint file
+type checkpoint struct {
+	Height string                    `json:"height"`
+	Files  map[string]checkpointFile `json:"files"`
+}
+
+// checkpointFile is the state of a table file once the checkpoint block was written
+type checkpointFile struct {
+	File    string   `json:"file"`
+	From    uint64   `json:"from"`
+	Size    int64    `json:"size"`
+	Columns []string `json:"columns"`
+}
+
+// tableFile is the file rows of a table are written to
+type tableFile struct {
+	checkpointFile
+	file *os.File
+}
+
+// NewFileSink opens a file sink in the given directory, rows written after the last checkpoint
+// (by a previous run stopped before checkpointing them) are discarded, along with files started after it
+func NewFileSink(dir, format string, maxSize int64, heights uint64, log *logger.Logger) (*FileSink, error) {
+	if format != FormatJSONL && format != FormatCSV {
+		return nil, errors.Errorf("Error opening file sink, unknown format %s (jsonl or csv)", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "Error creating file sink directory")
+	}
+
+	s := &FileSink{
+		Dir:     dir,
+		Format:  format,
+		MaxSize: maxSize,
+		Heights: heights,
+		Log:     log,
+		files:   make(map[string]*tableFile),
+	}
+
+	byteValue, err := ioutil.ReadFile(filepath.Join(dir, CheckpointFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "Error reading checkpoint file")
+	}
+	if err == nil {
+		if err = json.Unmarshal(byteValue, &s.checkpoint); err != nil {
+			return nil, errors.Wrap(err, "Error parsing checkpoint file")
+		}
+	}
+
+	// table files are truncated to their checkpointed size
+	for tableName, cf := range s.checkpoint.Files {
+		file, err := os.OpenFile(filepath.Join(dir, cf.File), os.O_WRONLY, 0644)
+		if err != nil {
+			s.Close()
+			return nil, errors.Wrapf(err, "Error opening file of table %s", tableName)
+		}
+		s.files[tableName] = &tableFile{checkpointFile: cf, file: file}
+
+		if err = file.Truncate(cf.Size); err != nil {
+			s.Close()
+			return nil, errors.Wrapf(err, "Error truncating file of table %s", tableName)
+		}
+		if _, err = file.Seek(cf.Size, 0); err != nil {
+			s.Close()
+			return nil, errors.Wrapf(err, "Error truncating file of table %s", tableName)
+		}
+	}
+
+	if err = s.removeStarted(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SynchronizeDB does nothing, table files are started as rows are written
+// (with the current columns of the table, so tables with changed columns start a new file)
+func (s *FileSink) SynchronizeDB(ctx context.Context, eventTables types.EventTables) error {
+	return nil
+}
+
+// GetCheckpoint returns the last block completely written
+func (s *FileSink) GetCheckpoint(ctx context.Context) (string, bool, error) {
+	return s.checkpoint.Height, s.checkpoint.Height != "", nil
+}
+
+// SetBlocks writes the rows of several consecutive blocks to table files, then checkpoints the last one
+// by replacing the checkpoint file, files are truncated back if the blocks cannot be written
+// (or if the context is cancelled before the checkpoint)
+func (s *FileSink) SetBlocks(ctx context.Context, eventTables types.EventTables, blocks []types.EventData) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	columns := getColumns(eventTables)
+	written := make(map[string]checkpointFile)
+	s.started = nil
+
+	err := s.writeBlocks(columns, blocks, written)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = s.setCheckpoint(blocks[len(blocks)-1].Block)
+	}
+
+	if err != nil {
+		s.rollback(written)
+		return err
+	}
+
+	return nil
+}
+
+// Close closes every table file
+func (s *FileSink) Close() {
+	for _, tf := range s.files {
+		tf.file.Close()
+	}
+}
+
+// writeBlocks writes rows of blocks to table files,
+// the state of every table file before being written is kept (to be rolled back)
+func (s *FileSink) writeBlocks(columns map[string][]string, blocks []types.EventData, written map[string]checkpointFile) error {
+	for _, blk := range blocks {
+		height, err := strconv.ParseUint(blk.Block, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "Error trying to convert block from string to uint64")
+		}
+
+		tableNames := make([]string, 0, len(blk.Tables))
+		for tableName := range blk.Tables {
+			tableNames = append(tableNames, tableName)
+		}
+		sort.Strings(tableNames)
+
+		for _, tableName := range tableNames {
+			if _, ok := written[tableName]; !ok {
+				if tf, ok := s.files[tableName]; ok {
+					written[tableName] = tf.checkpointFile
+				} else {
+					written[tableName] = checkpointFile{}
+				}
+			}
+
+			tf, err := s.getFile(tableName, height, columns[tableName])
+			if err != nil {
+				return err
+			}
+
+			for _, row := range blk.Tables[tableName] {
+				if err = s.writeRow(tf, row); err != nil {
+					return errors.Wrapf(err, "Error writing row of table %s", tableName)
+				}
+			}
+		}
+	}
+
+	for tableName := range written {
+		if err := s.files[tableName].file.Sync(); err != nil {
+			return errors.Wrapf(err, "Error syncing file of table %s", tableName)
+		}
+	}
+
+	return nil
+}
+
+// getFile returns the file to write rows of a table at the given height, starting a new file
+// once the current one is full, blocks leave its range of heights or columns of the table change
+func (s *FileSink) getFile(tableName string, height uint64, columns []string) (*tableFile, error) {
+	if tf, ok := s.files[tableName]; ok {
+		full := s.MaxSize > 0 && tf.Size >= s.MaxSize
+		outOfRange := s.Heights > 0 && height/s.Heights != tf.From/s.Heights
+
+		if !full && !outOfRange && equalColumns(tf.Columns, columns) {
+			return tf, nil
+		}
+
+		err := tf.file.Sync()
+		tf.file.Close()
+		delete(s.files, tableName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error syncing file of table %s", tableName)
+		}
+	}
+
+	name := fmt.Sprintf("%s-%d.%s", tableName, height, s.Format)
+
+	s.Log.Info("msg", "Starting table file", "table", tableName, "file", name)
+
+	file, err := os.OpenFile(filepath.Join(s.Dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error creating file of table %s", tableName)
+	}
+
+	tf := &tableFile{
+		checkpointFile: checkpointFile{File: name, From: height, Columns: columns},
+		file:           file,
+	}
+	s.files[tableName] = tf
+	s.started = append(s.started, name)
+
+	// CSV files start with a header of column names
+	if s.Format == FormatCSV {
+		if err = s.write(tf, columns); err != nil {
+			return nil, errors.Wrapf(err, "Error writing header of table %s", tableName)
+		}
+	}
+
+	return tf, nil
+}
+
+// writeRow writes a row as a JSON object or as CSV values ordered as the columns of the file
+func (s *FileSink) writeRow(tf *tableFile, row types.EventDataRow) error {
+	if s.Format == FormatCSV {
+		values := make([]string, len(tf.Columns))
+		for i, column := range tf.Columns {
+			values[i] = row[column]
+		}
+		return s.write(tf, values)
+	}
+
+	byteValue, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	n, err := tf.file.Write(append(byteValue, '\n'))
+	tf.Size += int64(n)
+	return err
+}
+
+// write writes a CSV record
+func (s *FileSink) write(tf *tableFile, record []string) error {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	w.Flush()
+
+	n, err := tf.file.Write(buf.Bytes())
+	tf.Size += int64(n)
+	return err
+}
+
+// setCheckpoint atomically replaces the checkpoint file with the given block and the size of every table file
+func (s *FileSink) setCheckpoint(block string) error {
+	cp := checkpoint{Height: block, Files: make(map[string]checkpointFile)}
+	for tableName, tf := range s.files {
+		cp.Files[tableName] = tf.checkpointFile
+	}
+
+	byteValue, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := filepath.Join(s.Dir, CheckpointFile+".tmp")
+
+	file, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "Error creating checkpoint file")
+	}
+	_, err = file.Write(byteValue)
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error writing checkpoint file")
+	}
+
+	if err = os.Rename(tmpFile, filepath.Join(s.Dir, CheckpointFile)); err != nil {
+		return errors.Wrap(err, "Error replacing checkpoint file")
+	}
+
+	s.checkpoint = cp
+	return nil
+}
+
+// rollback truncates table files back to their state before being written,
+// files started meanwhile are removed
+func (s *FileSink) rollback(written map[string]checkpointFile) {
+	for tableName, cf := range written {
+		if tf, ok := s.files[tableName]; ok && tf.File != cf.File {
+			tf.file.Close()
+			delete(s.files, tableName)
+		}
+
+		if cf.File == "" {
+			continue
+		}
+
+		tf, ok := s.files[tableName]
+		if !ok {
+			file, err := os.OpenFile(filepath.Join(s.Dir, cf.File), os.O_WRONLY, 0644)
+			if err != nil {
+				s.Log.Error("msg", "Error reopening file of table", "table", tableName, "err", err)
+				continue
+			}
+			tf = &tableFile{file: file}
+			s.files[tableName] = tf
+		}
+
+		tf.checkpointFile = cf
+		if err := tf.file.Truncate(cf.Size); err != nil {
+			s.Log.Error("msg", "Error truncating file of table", "table", tableName, "err", err)
+			continue
+		}
+		if _, err := tf.file.Seek(cf.Size, 0); err != nil {
+			s.Log.Error("msg", "Error truncating file of table", "table", tableName, "err", err)
+		}
+	}
+
+	for _, name := range s.started {
+		if err := os.Remove(filepath.Join(s.Dir, name)); err != nil {
+			s.Log.Error("msg", "Error removing table file", "file", name, "err", err)
+		}
+	}
+}
+
+// removeStarted removes table files started after the checkpoint (i.e. named after a later block)
+// and the temporary checkpoint file, left by a previous run stopped before checkpointing them
+func (s *FileSink) removeStarted() error {
+	if err := os.Remove(filepath.Join(s.Dir, CheckpointFile+".tmp")); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Error removing temporary checkpoint file")
+	}
+
+	var height uint64
+	if s.checkpoint.Height != "" {
+		var err error
+		if height, err = strconv.ParseUint(s.checkpoint.Height, 10, 64); err != nil {
+			return errors.Wrap(err, "Error trying to convert checkpoint from string to uint64")
+		}
+	}
+
+	infos, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return errors.Wrap(err, "Error reading file sink directory")
+	}
+
+	checkpointed := make(map[string]bool)
+	for _, cf := range s.checkpoint.Files {
+		checkpointed[cf.File] = true
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		ext := "." + s.Format
+		if info.IsDir() || checkpointed[name] || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		// files are named <table>-<height>.<format>
+		base := strings.TrimSuffix(name, ext)
+		i := strings.LastIndex(base, "-")
+		if i < 0 {
+			continue
+		}
+		from, err := strconv.ParseUint(base[i+1:], 10, 64)
+		if err != nil || (s.checkpoint.Height != "" && from <= height) {
+			continue
+		}
+
+		s.Log.Info("msg", "Removing table file started after the checkpoint", "file", name)
+
+		if err = os.Remove(filepath.Join(s.Dir, name)); err != nil {
+			return errors.Wrapf(err, "Error removing table file %s", name)
+		}
+	}
+
+	return nil
+}
+
+// getColumns returns the column names of each table (by table name), ordered as the table columns
+func getColumns(eventTables types.EventTables) map[string][]string {
+	columns := make(map[string][]string)
+
+	for _, table := range eventTables {
+		tableColumns := make([]types.SQLTableColumn, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			tableColumns = append(tableColumns, column)
+		}
+		sort.Slice(tableColumns, func(i, j int) bool {
+			if tableColumns[i].Order != tableColumns[j].Order {
+				return tableColumns[i].Order < tableColumns[j].Order
+			}
+			return tableColumns[i].Name < tableColumns[j].Name
+		})
+
+		names := make([]string, len(tableColumns))
+		for i, column := range tableColumns {
+			names[i] = column.Name
+		}
+		columns[table.Name] = names
+	}
+
+	return columns
+}
+
+// equalColumns checks both lists hold the same columns in the same order
+func equalColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package filesink_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/filesink"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBlocks(t *testing.T) {
+	t.Run("successfully writes rows as JSON lines and checkpoints the last block", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatJSONL, 0, 0)
+		defer sink.Close()
+
+		_, found, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, false, found)
+
+		err = sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a"), {Block: "2"}, getBlock("3", "b")})
+		require.NoError(t, err)
+
+		require.Equal(t, "{\"height\":\"1\",\"name\":\"a\"}\n{\"height\":\"3\",\"name\":\"b\"}\n", readFile(t, dir, "test_table-1.jsonl"))
+
+		height, found, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, "3", height)
+	})
+
+	t.Run("successfully writes rows as CSV records ordered as the table columns", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatCSV, 0, 0)
+		defer sink.Close()
+
+		err := sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a"), getBlock("2", "b,c")})
+		require.NoError(t, err)
+
+		require.Equal(t, "name,height\na,1\n\"b,c\",2\n", readFile(t, dir, "test_table-1.csv"))
+	})
+
+	t.Run("successfully starts new files by size and by range of heights", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatCSV, 20, 0)
+
+		err := sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a"), getBlock("2", "b"), getBlock("3", "c")})
+		require.NoError(t, err)
+		sink.Close()
+
+		require.Equal(t, "name,height\na,1\nb,2\n", readFile(t, dir, "test_table-1.csv"))
+		require.Equal(t, "name,height\nc,3\n", readFile(t, dir, "test_table-3.csv"))
+
+		dir, cleanup = tempDir(t)
+		defer cleanup()
+
+		sink = newFileSink(t, dir, filesink.FormatJSONL, 0, 10)
+		defer sink.Close()
+
+		err = sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("8", "a"), getBlock("9", "b"), getBlock("12", "c")})
+		require.NoError(t, err)
+
+		require.Equal(t, "{\"height\":\"8\",\"name\":\"a\"}\n{\"height\":\"9\",\"name\":\"b\"}\n", readFile(t, dir, "test_table-8.jsonl"))
+		require.Equal(t, "{\"height\":\"12\",\"name\":\"c\"}\n", readFile(t, dir, "test_table-12.jsonl"))
+	})
+
+	t.Run("rolls back the blocks if the context is cancelled", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatJSONL, 30, 0)
+		defer sink.Close()
+
+		err := sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a")})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = sink.SetBlocks(ctx, getTables(), []types.EventData{getBlock("2", "b"), getBlock("3", "c")})
+		require.Error(t, err)
+
+		require.Equal(t, "{\"height\":\"1\",\"name\":\"a\"}\n", readFile(t, dir, "test_table-1.jsonl"))
+		_, err = os.Stat(filepath.Join(dir, "test_table-3.jsonl"))
+		require.True(t, os.IsNotExist(err))
+
+		height, _, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1", height)
+
+		// blocks can be written again
+		err = sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("2", "b")})
+		require.NoError(t, err)
+
+		require.Equal(t, "{\"height\":\"1\",\"name\":\"a\"}\n{\"height\":\"2\",\"name\":\"b\"}\n", readFile(t, dir, "test_table-1.jsonl"))
+	})
+
+	t.Run("successfully resumes from the checkpoint discarding rows written after it", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatJSONL, 0, 0)
+
+		err := sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a")})
+		require.NoError(t, err)
+		sink.Close()
+
+		// rows of a block which was not checkpointed
+		file, err := os.OpenFile(filepath.Join(dir, "test_table-1.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = file.WriteString("{\"height\":\"2\",\"name\":\"b\"}\n")
+		require.NoError(t, err)
+		file.Close()
+
+		sink = newFileSink(t, dir, filesink.FormatJSONL, 0, 0)
+		defer sink.Close()
+
+		height, found, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, "1", height)
+
+		err = sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("2", "c")})
+		require.NoError(t, err)
+
+		require.Equal(t, "{\"height\":\"1\",\"name\":\"a\"}\n{\"height\":\"2\",\"name\":\"c\"}\n", readFile(t, dir, "test_table-1.jsonl"))
+	})
+}
+
+func TestNewFileSink(t *testing.T) {
+	t.Run("successfully removes files started after the checkpoint", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		sink := newFileSink(t, dir, filesink.FormatCSV, 20, 0)
+
+		err := sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("1", "a"), getBlock("2", "b"), getBlock("3", "c")})
+		require.NoError(t, err)
+		sink.Close()
+
+		// a file started by rotation and a half written checkpoint, as left by a run stopped before checkpointing
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test_table-4.csv"), []byte("name,height\nd,4\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, filesink.CheckpointFile+".tmp"), []byte("{\"height\":"), 0644))
+
+		sink = newFileSink(t, dir, filesink.FormatCSV, 20, 0)
+		defer sink.Close()
+
+		_, err = os.Stat(filepath.Join(dir, "test_table-4.csv"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, filesink.CheckpointFile+".tmp"))
+		require.True(t, os.IsNotExist(err))
+
+		// files started up to the checkpoint are kept
+		require.Equal(t, "name,height\na,1\nb,2\n", readFile(t, dir, "test_table-1.csv"))
+		require.Equal(t, "name,height\nc,3\n", readFile(t, dir, "test_table-3.csv"))
+
+		err = sink.SetBlocks(context.Background(), getTables(), []types.EventData{getBlock("4", "e")})
+		require.NoError(t, err)
+
+		require.Equal(t, "name,height\nc,3\ne,4\n", readFile(t, dir, "test_table-3.csv"))
+	})
+
+	t.Run("successfully removes files written without any checkpoint", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test_table-1.jsonl"), []byte("{}\n"), 0644))
+
+		sink := newFileSink(t, dir, filesink.FormatJSONL, 0, 0)
+		defer sink.Close()
+
+		_, err := os.Stat(filepath.Join(dir, "test_table-1.jsonl"))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("returns an error if the format is unknown", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		_, err := filesink.NewFileSink(dir, "xml", 0, 0, logger.NewLogger("none"))
+		require.Error(t, err)
+	})
+}
+
+func newFileSink(t *testing.T, dir, format string, maxSize int64, heights uint64) *filesink.FileSink {
+	sink, err := filesink.NewFileSink(dir, format, maxSize, heights, logger.NewLogger("none"))
+	require.NoError(t, err)
+	return sink
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "filesink")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	byteValue, err := ioutil.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return string(byteValue)
+}
+
+func getTables() types.EventTables {
+	return types.EventTables{
+		"TEST_EVENT": {
+			Name: "test_table",
+			Columns: map[string]types.SQLTableColumn{
+				"name":   {Name: "name", Type: types.SQLColumnTypeText, Primary: true, Order: 1},
+				"height": {Name: "height", Type: types.SQLColumnTypeText, Order: 2},
+			},
+		},
+	}
+}
+
+func getBlock(height, name string) types.EventData {
+	return types.EventData{
+		Block: height,
+		Tables: map[string]types.EventDataTable{
+			"test_table": {{"name": name, "height": height}},
+		},
+	}
+}
@@ -63,3 +63,8 @@ func (l *Logger) Info(args ...interface{}) {
 func (l *Logger) Debug(args ...interface{}) {
 	kitlevel.Debug(l.Log).Log(args...)
 }
+
+// With returns a logger adding the given key values to every log
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return NewLoggerFromKitlog(kitlog.With(l.Log, keyvals...))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/pkg/errors"
+)
+
+// blockBatch groups consecutive blocks to be committed in a single database transaction,
+// batches of one block (or less) commit every block on its own
+type blockBatch struct {
+	sink    Sink
+	tables  types.EventTables
+	log     *logger.Logger
+	size    int
+	timeout time.Duration
+	blocks  []types.EventData
+	timer   *time.Timer
+}
+
+// newBlockBatch constructs a batch of blocks using the consumer configuration
+func (c *Consumer) newBlockBatch(sink Sink, tables types.EventTables) *blockBatch {
+	return &blockBatch{
+		sink:    sink,
+		tables:  tables,
+		log:     c.Log,
+		size:    c.Config.BatchBlocks,
+		timeout: c.Config.BatchTimeout,
+	}
+}
+
+// add adds a block to the batch, the batch is committed once it is full or if the block is the latest one
+// (batches are committed by the caller once expired)
+func (b *blockBatch) add(ctx context.Context, blk types.EventData, latest bool) error {
+	b.blocks = append(b.blocks, blk)
+
+	if latest || len(b.blocks) >= b.size {
+		return b.commit(ctx)
+	}
+
+	if b.timer == nil && b.timeout > 0 {
+		b.timer = time.NewTimer(b.timeout)
+	}
+
+	return nil
+}
+
+// expired returns a channel receiving once the first block of the batch has been held for the timeout
+// (nil if the batch is empty, so it never receives)
+func (b *blockBatch) expired() <-chan time.Time {
+	if b.timer == nil {
+		return nil
+	}
+
+	return b.timer.C
+}
+
+// commit stores the rows of every block in the batch and checkpoints the last one
+func (b *blockBatch) commit(ctx context.Context) error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.blocks) == 0 {
+		return nil
+	}
+
+	if len(b.blocks) > 1 {
+		b.log.Info("msg", "Committing batch of blocks", "from", b.blocks[0].Block, "to", b.blocks[len(b.blocks)-1].Block, "blocks", len(b.blocks))
+	}
+
+	if err := b.sink.SetBlocks(ctx, b.tables, b.blocks); err != nil {
+		return errors.Wrap(err, "Error upserting rows in SQL event tables")
+	}
+
+	b.blocks = nil
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hyperledger/burrow/event/query"
+	"github.com/hyperledger/burrow/execution/errors"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/hyperledger/burrow/rpc/rpcevents"
+)
+
+// blockEvents contains the events of a block matching the events query,
+// along with the block header and every transaction of the block (only if requested),
+// events of transactions with exceptions are only included if requested,
+// along with the exceptions of those transactions (mapped by transaction hash)
+type blockEvents struct {
+	Height       uint64
+	Events       []*exec.Event
+	BlockHeader  *exec.BlockHeader
+	TxExecutions []*exec.TxExecution
+	TxExceptions map[string]*errors.Exception
+}
+
+// blockStream receives events of a block at a time
+type blockStream interface {
+	Recv() (*blockEvents, error)
+}
+
+// eventsStream receives matching events of each block from the events server
+type eventsStream struct {
+	stream EventsStream
+}
+
+// Recv receives the events of the next block with matching events
+func (s eventsStream) Recv() (*blockEvents, error) {
+	resp, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockEvents{Height: resp.Height, Events: resp.Events}, nil
+}
+
+// blocksStream receives whole blocks from the events server,
+// events are matched here the same way the events server does
+type blocksStream struct {
+	stream     BlocksStream
+	query      query.Query
+	exceptions bool
+}
+
+// Recv receives the next block with its header, transactions and matching events
+func (s blocksStream) Recv() (*blockEvents, error) {
+	block, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	blk := &blockEvents{Height: block.Height, BlockHeader: block.BlockHeader, TxExecutions: block.TxExecutions}
+
+	// events of transactions with exceptions are not streamed by the events server
+	for _, txe := range block.TxExecutions {
+		if txe.Exception != nil {
+			if !s.exceptions {
+				continue
+			}
+			if blk.TxExceptions == nil {
+				blk.TxExceptions = make(map[string]*errors.Exception)
+			}
+			blk.TxExceptions[string(txe.TxHash)] = txe.Exception
+		}
+		for _, ev := range txe.Events {
+			if s.query.Matches(ev.Tagged()) {
+				blk.Events = append(blk.Events, ev)
+			}
+		}
+	}
+
+	return blk, nil
+}
+
+// receivedBlock holds the events of a block received from a stream (or the error receiving them)
+type receivedBlock struct {
+	resp *blockEvents
+	err  error
+}
+
+// receiveBlocks receives blocks from the stream in the background until an error is received
+// or the context is cancelled, so receiving can be waited for along with other channels
+func receiveBlocks(ctx context.Context, evs blockStream) <-chan receivedBlock {
+	received := make(chan receivedBlock)
+
+	go func() {
+		for {
+			resp, err := evs.Recv()
+
+			select {
+			case received <- receivedBlock{resp: resp, err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return received
+}
+
+// blockSource opens streams of blocks of the events to be consumed
+type blockSource struct {
+	source      Source
+	eventsQuery *query.Builder
+	wholeBlocks bool
+	exceptions  bool
+}
+
+// open opens a stream of matching events in the given block range,
+// whole blocks are streamed if block headers, transactions or events of transactions
+// with exceptions are needed too
+func (s *blockSource) open(ctx context.Context, blockRange *rpcevents.BlockRange) (blockStream, error) {
+	if !s.wholeBlocks && !s.exceptions {
+		stream, err := s.source.Events(ctx, blockRange, s.eventsQuery.String())
+		if err != nil {
+			return nil, err
+		}
+		return eventsStream{stream: stream}, nil
+	}
+
+	qry, err := s.eventsQuery.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := s.source.Blocks(ctx, blockRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocksStream{stream: stream, query: qry, exceptions: s.exceptions}, nil
+}
+
+// latestHeight returns the height of the latest block of the chain
+func (s *blockSource) latestHeight(ctx context.Context) (uint64, error) {
+	return s.source.LatestHeight(ctx)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hyperledger/burrow/rpc/rpcevents"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/pkg/errors"
+)
+
+// HeightRange is an inclusive range of block heights
+type HeightRange struct {
+	From uint64
+	To   uint64
+}
+
+// CatchUpRanges splits the block heights between from and to (inclusive)
+// into consecutive ranges of the given size (the last one can be smaller)
+func CatchUpRanges(from, to, size uint64) []HeightRange {
+	var ranges []HeightRange
+
+	if size == 0 || from > to {
+		return ranges
+	}
+
+	for start := from; start <= to; start += size {
+		end := start + size - 1
+		if end > to || end < start {
+			end = to
+		}
+		ranges = append(ranges, HeightRange{From: start, To: end})
+		if end == to {
+			break
+		}
+	}
+
+	return ranges
+}
+
+// rangeBlocks holds the decoded blocks of a range (or the error getting them)
+type rangeBlocks struct {
+	blocks []types.EventData
+	err    error
+}
+
+// catchUp fetches and decodes ranges of blocks from the given one up to the latest confirmed one
+// with several workers, then commits them in height order, so checkpoints stay consistent,
+// it returns the block to keep consuming events from
+func (c *Consumer) catchUp(ctx, dbCtx context.Context, source *blockSource, parser *sqlsol.Parser,
+	systemTables *sqlsol.SystemTables, sink Sink, tables types.EventTables, from uint64) (uint64, error) {
+
+	latest, err := source.latestHeight(ctx)
+	if err != nil {
+		return from, streamError{errors.Wrap(err, "Error getting latest block height")}
+	}
+
+	// blocks within the confirmation lag are not caught up
+	to, ok := ConfirmedHeight(latest, c.Config.ConfirmationLag, c.Config.ToHeight)
+
+	// small gaps are consumed by a single stream
+	if !ok || to < from || to-from < c.Config.CatchUpRange {
+		return from, nil
+	}
+
+	ranges := CatchUpRanges(from, to, c.Config.CatchUpRange)
+	workers := c.Config.CatchUpWorkers
+
+	c.Log.Info("msg", "Catching up", "from", from, "to", to, "ranges", len(ranges), "workers", workers)
+
+	c.setConnected(true)
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan rangeBlocks, len(ranges))
+	for i := range results {
+		results[i] = make(chan rangeBlocks, 1)
+	}
+
+	// at most twice as many ranges as workers are held in memory before being committed
+	jobs := make(chan int)
+	window := make(chan struct{}, 2*workers)
+
+	go func() {
+		defer close(jobs)
+
+		for i := range ranges {
+			select {
+			case window <- struct{}{}:
+			case <-wctx.Done():
+				return
+			}
+
+			select {
+			case jobs <- i:
+			case <-wctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				blocks, err := c.getRangeBlocks(wctx, source, parser, systemTables, ranges[i])
+				results[i] <- rangeBlocks{blocks: blocks, err: err}
+			}
+		}()
+	}
+
+	// blocks are committed in height order, grouped in batches (if requested),
+	// pending blocks are committed before returning
+	batch := c.newBlockBatch(sink, tables)
+
+	for i, heightRange := range ranges {
+		var result rangeBlocks
+
+	wait:
+		for {
+			select {
+			case result = <-results[i]:
+				break wait
+			case <-batch.expired():
+				if err := batch.commit(dbCtx); err != nil {
+					return from, err
+				}
+			case <-ctx.Done():
+				return from, batch.commit(dbCtx)
+			}
+		}
+
+		if result.err != nil {
+			if err := batch.commit(dbCtx); err != nil {
+				return from, err
+			}
+			// the events streams are cancelled on shutdown
+			if ctx.Err() != nil {
+				return from, nil
+			}
+			return from, result.err
+		}
+
+		lastBlock := ""
+
+		for _, blk := range result.blocks {
+			c.Log.Info("msg", fmt.Sprintf("Upserting rows in SQL event tables %v", blk))
+
+			if err := batch.add(dbCtx, blk, false); err != nil {
+				return from, err
+			}
+			lastBlock = blk.Block
+		}
+
+		// the end of the range is checkpointed even if its last blocks had no events
+		if end := fmt.Sprintf("%v", heightRange.To); lastBlock != end {
+			if err := batch.add(dbCtx, types.EventData{Block: end}, false); err != nil {
+				return from, errors.Wrap(err, "Error checkpointing range of blocks")
+			}
+		}
+
+		from = heightRange.To + 1
+		<-window
+
+		// ranges received after the context is cancelled are not processed
+		if ctx.Err() != nil {
+			return from, batch.commit(dbCtx)
+		}
+	}
+
+	return from, batch.commit(dbCtx)
+}
+
+// getRangeBlocks receives and decodes the blocks of a range of heights
+func (c *Consumer) getRangeBlocks(ctx context.Context, source *blockSource, parser *sqlsol.Parser,
+	systemTables *sqlsol.SystemTables, heightRange HeightRange) ([]types.EventData, error) {
+
+	blockRange := rpcevents.NewBlockRange(rpcevents.AbsoluteBound(heightRange.From), rpcevents.AbsoluteBound(heightRange.To))
+
+	evs, err := source.open(ctx, blockRange)
+	if err != nil {
+		return nil, streamError{errors.Wrap(err, "Error connecting to events stream")}
+	}
+
+	var blocks []types.EventData
+
+	for {
+		resp, err := evs.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, streamError{errors.Wrap(err, "Error receiving events")}
+		}
+
+		c.resetReconnectAttempts()
+
+		blockData, err := c.getBlockData(parser, systemTables, resp)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, blockData.GetBlockData())
+	}
+
+	c.Log.Info("msg", "Range of blocks received", "from", heightRange.From, "to", heightRange.To, "blocks", len(blocks))
+
+	return blocks, nil
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatchUpRanges(t *testing.T) {
+	t.Run("successfully splits heights into consecutive ranges", func(t *testing.T) {
+		ranges := service.CatchUpRanges(5, 14, 4)
+		require.Equal(t, []service.HeightRange{{From: 5, To: 8}, {From: 9, To: 12}, {From: 13, To: 14}}, ranges)
+	})
+
+	t.Run("successfully returns a single range if heights fit in it", func(t *testing.T) {
+		ranges := service.CatchUpRanges(5, 6, 4)
+		require.Equal(t, []service.HeightRange{{From: 5, To: 6}}, ranges)
+	})
+
+	t.Run("returns no ranges if there are no heights", func(t *testing.T) {
+		require.Equal(t, 0, len(service.CatchUpRanges(7, 6, 4)))
+		require.Equal(t, 0, len(service.CatchUpRanges(5, 6, 0)))
+	})
+}
+
+func TestCatchUp(t *testing.T) {
+	cfgFile, removeCfgFile := writeCfgFile(t, test.GoodJSONConfFile(t))
+	defer removeCfgFile()
+
+	cfg := config.DefaultFlags()
+	cfg.CfgFile = cfgFile
+	cfg.LogLevel = "none"
+	cfg.CatchUpWorkers = 3
+	cfg.CatchUpRange = 4
+	cfg.BatchBlocks = 3
+	cfg.BatchTimeout = time.Minute
+
+	t.Run("successfully commits sparse blocks caught up by several workers in height order", func(t *testing.T) {
+		source := &memorySource{}
+		for height := uint64(1); height <= 20; height++ {
+			switch height {
+			case 2, 7, 8, 15, 19:
+				source.addBlock(t, height, fmt.Sprintf("TestEvent%d", height))
+			default:
+				source.addBlock(t, height)
+			}
+		}
+
+		sink := &memorySink{}
+
+		consumer := service.NewConsumer(cfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		// ranges 0-3, 4-7, 8-11, 12-15, 16-19 and 20 are committed in batches of 3 blocks,
+		// with a checkpoint for ranges whose last block has no events
+		require.Equal(t, [][]string{{"2", "3", "7"}, {"8", "11", "15"}, {"19", "20"}}, sink.commits)
+		require.Equal(t, []string{"TestEvent2", "TestEvent7", "TestEvent8", "TestEvent15", "TestEvent19"}, sink.names("eventtest"))
+
+		height, found, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, "20", height)
+	})
+
+	t.Run("successfully resumes catching up from the sink checkpoint", func(t *testing.T) {
+		source := &memorySource{}
+		for height := uint64(1); height <= 12; height++ {
+			source.addBlock(t, height, fmt.Sprintf("TestEvent%d", height))
+		}
+
+		sink := &memorySink{}
+		require.NoError(t, sink.SetBlocks(context.Background(), nil, []types.EventData{{Block: "3"}}))
+
+		consumer := service.NewConsumer(cfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, [][]string{{"3"}, {"4", "5", "6"}, {"7", "8", "9"}, {"10", "11", "12"}}, sink.commits)
+
+		height, _, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "12", height)
+	})
+}
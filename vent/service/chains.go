@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/pkg/errors"
+)
+
+// ChainStatus contains the state of the consumer of a chain
+type ChainStatus struct {
+	Status
+	Running bool
+	Error   string
+}
+
+// MultiConsumer runs an independent consumer for each chain,
+// so a failing chain does not stop the others
+type MultiConsumer struct {
+	Consumers map[string]*Consumer
+	Log       *logger.Logger
+	mtx       sync.Mutex
+	running   map[string]bool
+	errs      map[string]error
+}
+
+// NewMultiConsumer constructs a consumer for each chain, chains configuration overrides the given flags
+func NewMultiConsumer(cfg *config.Flags, chains []config.Chain, log *logger.Logger) *MultiConsumer {
+	consumers := make(map[string]*Consumer)
+
+	for _, chain := range chains {
+		consumers[chain.Name] = NewConsumer(cfg.ForChain(chain), log.With("chain", chain.Name))
+	}
+
+	return &MultiConsumer{
+		Consumers: consumers,
+		Log:       log,
+		running:   make(map[string]bool),
+		errs:      make(map[string]error),
+	}
+}
+
+// Run runs every chain consumer until all of them are done (or have failed),
+// an error listing failed chains is returned if any
+func (m *MultiConsumer) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	for name, consumer := range m.Consumers {
+		wg.Add(1)
+		m.setRunning(name)
+
+		go func(name string, consumer *Consumer) {
+			defer wg.Done()
+
+			err := consumer.Run(ctx)
+			if err != nil {
+				m.Log.Error("msg", "Chain consumer failed", "chain", name, "err", err)
+			}
+
+			m.setDone(name, err)
+		}(name, consumer)
+	}
+
+	wg.Wait()
+
+	return m.err()
+}
+
+// Reload requests every chain consumer to read its events config file again
+func (m *MultiConsumer) Reload() {
+	for _, consumer := range m.Consumers {
+		consumer.Reload()
+	}
+}
+
+// Status returns the state of every chain consumer
+func (m *MultiConsumer) Status() map[string]ChainStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	status := make(map[string]ChainStatus)
+
+	for name, consumer := range m.Consumers {
+		chainStatus := ChainStatus{
+			Status:  consumer.Status(),
+			Running: m.running[name],
+		}
+		if err := m.errs[name]; err != nil {
+			chainStatus.Error = err.Error()
+		}
+		status[name] = chainStatus
+	}
+
+	return status
+}
+
+// setRunning updates the status once a chain consumer is started
+func (m *MultiConsumer) setRunning(name string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.running[name] = true
+	delete(m.errs, name)
+}
+
+// setDone updates the status once a chain consumer is done
+func (m *MultiConsumer) setDone(name string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.running[name] = false
+	if err != nil {
+		m.errs[name] = err
+	}
+}
+
+// err returns an error listing failed chains (nil if none has failed)
+func (m *MultiConsumer) err() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if len(m.errs) == 0 {
+		return nil
+	}
+
+	failed := make([]string, 0, len(m.errs))
+	for name, err := range m.errs {
+		failed = append(failed, name+": "+err.Error())
+	}
+	sort.Strings(failed)
+
+	return errors.Errorf("Error consuming events of chains (%s)", strings.Join(failed, "; "))
+}
@@ -0,0 +1,32 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiConsumer(t *testing.T) {
+	t.Run("returns an error listing every failed chain", func(t *testing.T) {
+		chains := []config.Chain{
+			{Name: "main", GRPCAddr: "localhost:10997", DBSchema: "main", CfgFile: "missing_main.json"},
+			{Name: "test", GRPCAddr: "localhost:20997", DBSchema: "test", CfgFile: "missing_test.json"},
+		}
+
+		consumer := service.NewMultiConsumer(config.DefaultFlags(), chains, logger.NewLogger("none"))
+
+		err := consumer.Run(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "main: ")
+		require.Contains(t, err.Error(), "test: ")
+
+		status := consumer.Status()
+		require.Equal(t, 2, len(status))
+		require.Equal(t, false, status["main"].Running)
+		require.NotEqual(t, "", status["main"].Error)
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/burrow/rpc/rpcevents"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/pkg/errors"
+)
+
+// confirmationPollInterval is the delay between checks for new confirmed blocks in follow mode
+const confirmationPollInterval = time.Second
+
+// ConfirmedHeight returns the last block to consume given the latest block of the chain,
+// i.e. the latest block less the confirmation lag (up to the last block to consume, if given),
+// false is returned if no block is confirmed yet
+func ConfirmedHeight(latest, lag, toHeight uint64) (uint64, bool) {
+	if latest < lag {
+		return 0, false
+	}
+
+	height := latest - lag
+	if toHeight > 0 && toHeight < height {
+		height = toHeight
+	}
+
+	return height, true
+}
+
+// consumeConfirmed consumes blocks up to the latest block less the confirmation lag (up to the last block to consume, if given),
+// in follow mode new confirmed blocks are polled for until the last block to consume is reached
+// (as the events server can only stream up to the latest block)
+func (c *Consumer) consumeConfirmed(ctx, dbCtx context.Context, source *blockSource, parser *sqlsol.Parser,
+	systemTables *sqlsol.SystemTables, sink Sink, tables types.EventTables, startingBlock uint64) error {
+
+	for {
+		latest, err := source.latestHeight(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return streamError{errors.Wrap(err, "Error getting latest block height")}
+		}
+
+		to, ok := ConfirmedHeight(latest, c.Config.ConfirmationLag, c.Config.ToHeight)
+		if ok && startingBlock <= to {
+			blockRange := rpcevents.NewBlockRange(rpcevents.AbsoluteBound(startingBlock), rpcevents.AbsoluteBound(to))
+
+			if err = c.consumeRange(ctx, dbCtx, source, parser, systemTables, sink, tables, blockRange, startingBlock); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			// the end of the range is checkpointed even if its last blocks had no events
+			// (so the range is not consumed again)
+			if err = sink.SetBlocks(dbCtx, tables, []types.EventData{{Block: fmt.Sprintf("%v", to)}}); err != nil {
+				return errors.Wrap(err, "Error checkpointing range of blocks")
+			}
+			startingBlock = to + 1
+		}
+
+		if c.Config.ToHeight > 0 && startingBlock > c.Config.ToHeight {
+			c.Log.Info("msg", "Last block already processed", "value", c.Config.ToHeight)
+			return nil
+		}
+
+		if !c.Config.Follow {
+			if c.Config.ToHeight > 0 {
+				c.Log.Warn("msg", "Last block to consume not produced (or confirmed) yet, range cut short at the latest block",
+					"to", c.Config.ToHeight, "latest", latest, "lag", c.Config.ConfirmationLag)
+			}
+			return nil
+		}
+
+		c.setConnected(true)
+		c.Log.Debug("msg", "Waiting for confirmed blocks", "latest", latest, "lag", c.Config.ConfirmationLag)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(confirmationPollInterval):
+		}
+	}
+}
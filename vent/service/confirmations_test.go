@@ -0,0 +1,35 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmedHeight(t *testing.T) {
+	t.Run("successfully stays behind the latest block by the confirmation lag", func(t *testing.T) {
+		height, ok := service.ConfirmedHeight(20, 5, 0)
+		require.Equal(t, true, ok)
+		require.Equal(t, uint64(15), height)
+
+		height, ok = service.ConfirmedHeight(20, 0, 0)
+		require.Equal(t, true, ok)
+		require.Equal(t, uint64(20), height)
+	})
+
+	t.Run("successfully stops at the last block to consume", func(t *testing.T) {
+		height, ok := service.ConfirmedHeight(20, 5, 10)
+		require.Equal(t, true, ok)
+		require.Equal(t, uint64(10), height)
+
+		height, ok = service.ConfirmedHeight(20, 5, 18)
+		require.Equal(t, true, ok)
+		require.Equal(t, uint64(15), height)
+	})
+
+	t.Run("returns no height if no block is confirmed yet", func(t *testing.T) {
+		_, ok := service.ConfirmedHeight(4, 5, 0)
+		require.Equal(t, false, ok)
+	})
+}
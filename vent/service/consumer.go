@@ -6,14 +6,17 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/hyperledger/burrow/event"
 	"github.com/hyperledger/burrow/event/query"
 	"github.com/hyperledger/burrow/execution/exec"
 	"github.com/hyperledger/burrow/rpc/rpcevents"
 	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/filesink"
 	"github.com/monax/bosmarmot/vent/logger"
 	"github.com/monax/bosmarmot/vent/sqldb"
 	"github.com/monax/bosmarmot/vent/sqlsol"
@@ -22,12 +25,18 @@ import (
 	"google.golang.org/grpc"
 )
 
-// Consumer contains basic configuration for consumer to run
+// Consumer contains basic configuration for consumer to run,
+// blocks are consumed from Source into Sink (a Burrow node and SQL event tables or files
+// given by the configuration are used if they are not set)
 type Consumer struct {
 	Config           *config.Flags
 	Log              *logger.Logger
 	EventLogDecoders map[string]EventLogDecoder
-	Closing          bool
+	Source           Source
+	Sink             Sink
+	mtx              sync.Mutex
+	status           Status
+	reload           chan struct{}
 }
 
 // NewConsumer constructs a new consumer configuration
@@ -36,7 +45,7 @@ func NewConsumer(cfg *config.Flags, log *logger.Logger) *Consumer {
 		Config:           cfg,
 		Log:              log,
 		EventLogDecoders: make(map[string]EventLogDecoder),
-		Closing:          false,
+		reload:           make(chan struct{}, 1),
 	}
 }
 
@@ -45,189 +54,571 @@ func (c *Consumer) AddEventLogDecoder(eventName string, eventLogDecoder EventLog
 	c.EventLogDecoders[eventName] = eventLogDecoder
 }
 
+// Reload requests the events config file to be read again while running,
+// blocks are then consumed with the new mapping (the current one is kept if the new one is broken)
+func (c *Consumer) Reload() {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+}
+
 // Run connects to a grpc service and subscribes to log events,
 // then gets tables structures, maps them & parse event data.
-// Store data in SQL event tables, it runs forever
-func (c *Consumer) Run() error {
-	c.Log.Info("msg", "Reading events config file")
-
-	byteValue, err := readFile(c.Config.CfgFile)
+// Store data in SQL event tables, it runs until the latest block is reached
+// (or forever in follow mode) or until the context is cancelled,
+// the block (or batch of blocks) in flight when the context is cancelled is given the drain timeout to be committed
+func (c *Consumer) Run(ctx context.Context) error {
+	parser, err := c.newParser()
 	if err != nil {
-		return errors.Wrap(err, "Error reading events config file")
+		return err
 	}
 
-	c.Log.Info("msg", "Parsing and mapping events config stream")
-
-	parser, err := sqlsol.NewParser(byteValue)
+	systemTables, err := sqlsol.NewSystemTables(c.Config.SystemEvents)
 	if err != nil {
-		return errors.Wrap(err, "Error mapping events config stream")
+		return errors.Wrap(err, "Error mapping system events")
 	}
 
-	tables := parser.GetTables()
+	tables := getTables(parser, systemTables)
 
-	c.Log.Info("msg", "Connecting to SQL database")
+	sink := c.Sink
+	if sink == nil && c.Config.Sink != "sql" {
+		dir := filepath.Join(c.Config.FileDir, c.Config.DBSchema)
 
-	db, err := sqldb.NewSQLDB(c.Config.DBAdapter, c.Config.DBURL, c.Config.DBSchema, c.Log)
-	if err != nil {
-		return errors.Wrap(err, "Error connecting to SQL")
+		c.Log.Info("msg", "Opening files", "dir", dir, "format", c.Config.Sink)
+
+		fileSink, err := filesink.NewFileSink(dir, c.Config.Sink, c.Config.FileMaxSize, c.Config.FileHeights, c.Log)
+		if err != nil {
+			return errors.Wrap(err, "Error opening files")
+		}
+		defer fileSink.Close()
+
+		sink = fileSink
+	}
+	var db *sqldb.SQLDB
+	if sink == nil {
+		c.Log.Info("msg", "Connecting to SQL database")
+
+		db, err = sqldb.NewSQLDB(c.Config.DBAdapter, c.Config.DBURL, c.Config.DBSchema, c.Log)
+		if err != nil {
+			return errors.Wrap(err, "Error connecting to SQL")
+		}
+		defer func() {
+			if db != nil {
+				db.Close()
+			}
+		}()
+
+		sink = db
 	}
-	defer db.Close()
 
 	c.Log.Info("msg", "Synchronizing config and database parser structures")
 
-	err = db.SynchronizeDB(tables)
+	err = sink.SynchronizeDB(ctx, tables)
 	if err != nil {
 		return errors.Wrap(err, "Error trying to synchronize database")
 	}
 
-	c.Log.Info("msg", "Getting last processed block number from SQL log table")
+	source := c.Source
+	if source == nil {
+		c.Log.Info("msg", "Connecting to Burrow gRPC server")
 
-	fromBlock, err := db.GetLastBlockID()
-	if err != nil {
-		return errors.Wrap(err, "Error trying to get last processed block number from SQL log table")
+		conn, err := grpc.Dial(c.Config.GRPCAddr, grpc.WithInsecure())
+		if err != nil {
+			return errors.Wrapf(err, "Error connecting to Burrow gRPC server at %s", c.Config.GRPCAddr)
+		}
+		defer conn.Close()
+
+		source = NewGRPCSource(conn)
 	}
 
-	// right now there is no way to know if the last block of events was completely read
-	// so we have to begin processing from the last block number stored in database
-	// and update event data if already present
+	// database operations outlive the context by the drain timeout,
+	// then the in-flight block transaction is rolled back
+	dbCtx, cancelDB := context.WithCancel(context.Background())
+	defer cancelDB()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Log.Info("msg", "Shutting down...", "drain", c.Config.DrainTimeout)
+		case <-dbCtx.Done():
+			return
+		}
 
-	// string to uint64 from event filtering
-	startingBlock, err := strconv.ParseUint(fromBlock, 10, 64)
-	if err != nil {
-		return errors.Wrap(err, "Error trying to convert fromBlock from string to uint64")
+		select {
+		case <-time.After(c.Config.DrainTimeout):
+			cancelDB()
+		case <-dbCtx.Done():
+		}
+	}()
+
+	// events stream errors are recovered by reconnecting (and resuming from the last checkpoint)
+	// until the maximum number of consecutive attempts is reached
+	for ctx.Err() == nil {
+		// on reload requests, blocks in flight are committed as on shutdown,
+		// then events are consumed again from the checkpoint with the new mapping
+		consumeCtx, reloaded := c.watchReload(ctx)
+
+		err = c.consumeEvents(consumeCtx, dbCtx, source, parser, systemTables, sink)
+
+		if reloaded() && ctx.Err() == nil {
+			parser = c.reloadParser(ctx, sink, parser, systemTables)
+			if err == nil {
+				continue
+			}
+		}
+
+		if err == nil {
+			break
+		}
+
+		// once the schema has been swapped by a reindex, the new schema is connected to and events are consumed
+		// from its checkpoint, the consumer own events config file is read again (so it has to be replaced
+		// with the one the schema was rebuilt with before the swap), a sink given by the caller can not be
+		// reconnected so the consumer stops
+		if errors.Cause(err) == sqldb.ErrSchemaSwapped && ctx.Err() == nil {
+			if db == nil {
+				c.Log.Warn("msg", "Schema swapped, restart to consume events into the new schema", "value", c.Config.DBSchema)
+				break
+			}
+
+			c.Log.Info("msg", "Schema swapped, reconnecting to SQL database", "value", c.Config.DBSchema)
+
+			db.Close()
+			db, err = sqldb.NewSQLDB(c.Config.DBAdapter, c.Config.DBURL, c.Config.DBSchema, c.Log)
+			if err != nil {
+				return errors.Wrap(err, "Error connecting to SQL")
+			}
+
+			sink = db
+			parser = c.reloadParser(ctx, sink, parser, systemTables)
+			continue
+		}
+
+		streamErr, ok := err.(streamError)
+		if !ok {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			// the events stream is cancelled on shutdown
+			break
+		}
+
+		attempt := c.addReconnectAttempt(streamErr.error)
+		if attempt > c.Config.GRPCMaxRetries {
+			return errors.Wrapf(streamErr.error, "Error receiving events after %d reconnection attempts", attempt-1)
+		}
+
+		delay := ReconnectBackoff(attempt, c.Config.GRPCRetryBackoff, maxReconnectBackoff)
+		c.Log.Warn("msg", "Events stream failed, reconnecting", "err", streamErr.error, "attempt", attempt, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
 	}
 
-	c.Log.Info("msg", "Connecting to Burrow gRPC server")
+	c.Log.Info("msg", "Done!")
+	return nil
+}
+
+// consumeEvents subscribes to log events from the block following the last checkpoint,
+// then decodes them and stores their rows in SQL event tables (using the database context)
+func (c *Consumer) consumeEvents(ctx, dbCtx context.Context, source Source,
+	parser *sqlsol.Parser, systemTables *sqlsol.SystemTables, sink Sink) error {
 
-	conn, err := grpc.Dial(c.Config.GRPCAddr, grpc.WithInsecure())
+	c.Log.Info("msg", "Getting last processed block number from SQL checkpoint table")
+
+	startingBlock, err := c.getStartingBlock(dbCtx, sink)
 	if err != nil {
-		return errors.Wrapf(err, "Error connecting to Burrow gRPC server at %s", c.Config.GRPCAddr)
+		return err
+	}
+
+	tables := getTables(parser, systemTables)
+
+	// log events not matching the filter conditions shared by all events are discarded by the server,
+	// but the query grammar has no OR, so every event is requested when system events are stored
+	eventsQuery := query.NewBuilder().AndEquals(event.EventTypeKey, exec.TypeLog.String()).And(parser.GetEventsQuery())
+	if systemTables.StoresEvents() {
+		eventsQuery = query.NewBuilder()
+	}
+
+	// whole blocks are streamed when blocks or transactions are stored, or block times are needed
+	// (or events of transactions with exceptions, which are not streamed by the events server)
+	blocks := &blockSource{
+		source:      source,
+		eventsQuery: eventsQuery,
+		wholeBlocks: systemTables.StoresBlocks() || systemTables.StoresTxs() || c.Config.BlockTime,
+		exceptions:  parser.StoresExceptions(),
 	}
-	defer conn.Close()
 
-	cli := rpcevents.NewExecutionEventsClient(conn)
+	// the gap between the checkpoint and the latest block is caught up by several workers (if requested)
+	if c.Config.CatchUpWorkers > 1 {
+		if startingBlock, err = c.catchUp(ctx, dbCtx, blocks, parser, systemTables, sink, tables, startingBlock); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
 
-	request := &rpcevents.BlocksRequest{
-		Query:      query.NewBuilder().AndEquals(event.EventTypeKey, exec.TypeLog.String()).String(),
-		BlockRange: rpcevents.NewBlockRange(rpcevents.AbsoluteBound(startingBlock), rpcevents.LatestBound()),
+	// with a confirmation lag or a last block to consume, blocks are consumed up to the latest block
+	// less the lag (and up to the last block to consume)
+	if c.Config.ConfirmationLag > 0 || c.Config.ToHeight > 0 {
+		return c.consumeConfirmed(ctx, dbCtx, blocks, parser, systemTables, sink, tables, startingBlock)
 	}
-	evs, err := cli.GetEvents(context.Background(), request)
+
+	// in follow mode blocks are streamed as they are produced once the latest one is reached
+	endBound := rpcevents.LatestBound()
+	if c.Config.Follow {
+		endBound = rpcevents.StreamBound()
+	}
+
+	blockRange := rpcevents.NewBlockRange(rpcevents.AbsoluteBound(startingBlock), endBound)
+
+	return c.consumeRange(ctx, dbCtx, blocks, parser, systemTables, sink, tables, blockRange, startingBlock)
+}
+
+// consumeRange streams the blocks of the given range, then decodes their events
+// and stores their rows in SQL event tables (using the database context)
+func (c *Consumer) consumeRange(ctx, dbCtx context.Context, source *blockSource, parser *sqlsol.Parser, systemTables *sqlsol.SystemTables,
+	sink Sink, tables types.EventTables, blockRange *rpcevents.BlockRange, startingBlock uint64) error {
+
+	// the events stream is closed once done
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+
+	evs, err := source.open(streamCtx, blockRange)
 	if err != nil {
-		return errors.Wrap(err, "Error connecting to events stream")
+		return streamError{errors.Wrap(err, "Error connecting to events stream")}
 	}
 
-	// a fresh new structure to store block data
-	blockData := sqlsol.NewBlockData()
+	c.setConnected(true)
 
-	// Grab the events
-	for {
-		if c.Closing {
-			break
+	// blocks are committed in batches (if requested) until the latest block is reached,
+	// then one at a time, pending blocks are committed before returning
+	batch := c.newBlockBatch(sink, tables)
+
+	latest := uint64(0)
+	if c.Config.BatchBlocks > 1 {
+		if latest, err = source.latestHeight(ctx); err != nil {
+			return streamError{errors.Wrap(err, "Error getting latest block height")}
 		}
+	}
+
+	received := receiveBlocks(streamCtx, evs)
 
+	// Grab the events
+	for {
 		c.Log.Info("msg", "Waiting for events")
 
-		resp, err := evs.Recv()
+		var recv receivedBlock
+
+		select {
+		case recv = <-received:
+		case <-batch.expired():
+			if err = batch.commit(dbCtx); err != nil {
+				return err
+			}
+			continue
+		case <-ctx.Done():
+			return batch.commit(dbCtx)
+		}
+
+		resp, err := recv.resp, recv.err
 		if err != nil {
-			if err == io.EOF {
+			if errCommit := batch.commit(dbCtx); errCommit != nil {
+				return errCommit
+			}
+
+			// the events stream is cancelled on shutdown
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			// streams of blocks as they are produced never end, unless the node goes down
+			if err == io.EOF && blockRange.GetEnd().GetType() != rpcevents.Bound_STREAM {
 				c.Log.Info("msg", "EOF received")
 				break
-			} else {
-				return errors.Wrap(err, "Error receiving events")
 			}
+			return streamError{errors.Wrap(err, "Error receiving events")}
 		}
 
-		c.Log.Info("msg", fmt.Sprintf("Events received: %v", len(resp.Events)))
+		c.resetReconnectAttempts()
 
-		// get event data
-		for _, event := range resp.Events {
-			// a fresh new row to store column/value data
-			row := make(types.EventDataRow)
+		c.Log.Info("msg", fmt.Sprintf("Events received: %v", len(resp.Events)))
 
-			// GetHeader gets Header data for the given event
-			// GetLog gets log event data for the given event
-			eventHeader := event.GetHeader()
-			eventLog := event.GetLog()
+		// blocks already checkpointed are not processed again
+		if resp.Height < startingBlock {
+			c.Log.Debug("msg", "Skipping processed block", "value", resp.Height)
+			continue
+		}
 
-			// decode event data using the provided event log decoders
-			eventData := DecodeEvent(eventHeader, eventLog, c.EventLogDecoders)
+		// each response holds every matching event (and transaction) of a block,
+		// so block data is stored as soon as the response is processed
+		blockData, err := c.getBlockData(parser, systemTables, resp)
+		if err != nil {
+			return err
+		}
 
-			// ------------------------------------------------
-			// if source block number is different than current...
-			// upsert rows in specific SQL event tables and update block number
-			eventBlockID := fmt.Sprintf("%v", eventHeader.GetHeight())
+		// gets block data to upsert
+		blk := blockData.GetBlockData()
 
-			if strings.TrimSpace(fromBlock) != strings.TrimSpace(eventBlockID) {
-				// store block data in SQL tables (if any)
-				if blockData.PendingRows(fromBlock) {
+		c.Log.Info("msg", fmt.Sprintf("Upserting rows in SQL event tables %v", blk))
 
-					// gets block data to upsert
-					blk := blockData.GetBlockData()
+		// upsert rows in specific SQL event tables, update block number and checkpoint
+		// (blocks without rows are checkpointed too)
+		if err = batch.add(dbCtx, blk, resp.Height >= latest); err != nil {
+			return err
+		}
 
-					c.Log.Info("msg", fmt.Sprintf("Upserting rows in SQL event tables %v", blk))
+		// blocks received after the context is cancelled are not processed
+		if ctx.Err() != nil {
+			return batch.commit(dbCtx)
+		}
+	}
 
-					// upsert rows in specific SQL event tables and update block number
-					err = db.SetBlock(tables, blk)
-					if err != nil {
-						return errors.Wrap(err, "Error upserting rows in SQL event tables")
-					}
-				}
+	return nil
+}
 
-				// end of block setter, clear blockData structure
-				blockData = sqlsol.NewBlockData()
+// newParser reads the events config file and maps its events to SQL event tables
+func (c *Consumer) newParser() (*sqlsol.Parser, error) {
+	c.Log.Info("msg", "Reading events config file")
 
-				// set new block number
-				fromBlock = eventBlockID
-			}
+	byteValue, err := readFile(c.Config.CfgFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading events config file")
+	}
 
-			// get eventName to map to SQL tableName
-			eventName := eventData["eventName"]
-			tableName, err := parser.GetTableName(eventName)
-			if err != nil {
-				return err
-			}
+	c.Log.Info("msg", "Parsing and mapping events config stream")
 
-			// for each data element, maps to SQL columnName and gets its value
-			for k, v := range eventData {
-				columnName, err := parser.GetColumnName(eventName, k)
-				if err != nil {
-					return err
-				}
+	parser, err := sqlsol.NewParser(byteValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error mapping events config stream")
+	}
 
-				row[columnName] = v
-			}
+	if c.Config.BlockTime {
+		if err = parser.AddBlockTimeColumn(); err != nil {
+			return nil, errors.Wrap(err, "Error adding block time column")
+		}
+	}
 
-			// so, the row is filled with data, update structure
-			// store block number
-			blockData.SetBlockID(fromBlock)
+	return parser, nil
+}
 
-			// set row in structure
-			blockData.AddRow(tableName, row)
+// watchReload returns a context cancelled once a reload is requested,
+// along with a function to stop watching which reports whether a reload was requested
+func (c *Consumer) watchReload(ctx context.Context) (context.Context, func() bool) {
+	reloadCtx, cancel := context.WithCancel(ctx)
+	requested := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-c.reload:
+			requested <- true
+			cancel()
+		case <-reloadCtx.Done():
+			requested <- false
 		}
+	}()
+
+	return reloadCtx, func() bool {
+		cancel()
+		return <-requested
 	}
+}
 
-	// store pending block data in SQL tables (if any)
-	if blockData.PendingRows(fromBlock) {
-		// gets block data to upsert
-		blk := blockData.GetBlockData()
+// reloadParser reads the events config file again and synchronizes new or changed tables,
+// the given parser is kept if the events config file is broken or tables cannot be synchronized
+func (c *Consumer) reloadParser(ctx context.Context, sink Sink, parser *sqlsol.Parser, systemTables *sqlsol.SystemTables) *sqlsol.Parser {
+	c.Log.Info("msg", "Reloading events config file")
 
-		c.Log.Info("msg", fmt.Sprintf("Upserting rows in SQL event tables %v", blk))
+	newParser, err := c.newParser()
+	if err != nil {
+		c.Log.Error("msg", "Error reloading events config file, keeping the current one", "err", err)
+		return parser
+	}
+
+	if err = sink.SynchronizeDB(ctx, getTables(newParser, systemTables)); err != nil {
+		c.Log.Error("msg", "Error synchronizing reloaded events config, keeping the current one", "err", err)
+		return parser
+	}
+
+	c.Log.Info("msg", "Events config file reloaded")
 
-		// upsert rows in specific SQL event tables and update block number
-		err = db.SetBlock(tables, blk)
+	return newParser
+}
+
+// getTables returns the event tables structures along with the system tables ones
+func getTables(parser *sqlsol.Parser, systemTables *sqlsol.SystemTables) types.EventTables {
+	tables := make(types.EventTables)
+
+	for key, table := range parser.GetTables() {
+		tables[key] = table
+	}
+
+	for key, table := range systemTables.GetTables() {
+		tables[key] = table
+	}
+
+	return tables
+}
+
+// getStartingBlock returns the block to resume consuming events from,
+// the one following the last block checkpointed as completely processed
+// (or the first block to consume if it comes later)
+func (c *Consumer) getStartingBlock(ctx context.Context, sink Sink) (uint64, error) {
+	checkpoint, found, err := sink.GetCheckpoint(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error trying to get last processed block number from SQL checkpoint table")
+	}
+
+	if found {
+		height, err := strconv.ParseUint(checkpoint, 10, 64)
 		if err != nil {
-			return errors.Wrap(err, "Error upserting rows in SQL event tables")
+			return 0, errors.Wrap(err, "Error trying to convert checkpoint from string to uint64")
 		}
+		if height+1 < c.Config.FromHeight {
+			return c.Config.FromHeight, nil
+		}
+		return height + 1, nil
 	}
 
-	c.Log.Info("msg", "Done!")
-	return nil
+	// databases written before checkpoints were stored can only resume from the last logged block,
+	// upserting its rows again (other sinks start from the first block to consume)
+	logs, ok := sink.(logSink)
+	if !ok {
+		return c.Config.FromHeight, nil
+	}
+
+	fromBlock, err := logs.GetLastBlockID(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error trying to get last processed block number from SQL log table")
+	}
+
+	height, err := strconv.ParseUint(fromBlock, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error trying to convert fromBlock from string to uint64")
+	}
+
+	if height < c.Config.FromHeight {
+		return c.Config.FromHeight, nil
+	}
+
+	return height, nil
 }
 
-// Shutdown gracefully shuts down the events consumer
-func (c *Consumer) Shutdown() {
-	c.Log.Info("msg", "Shutting down...")
-	c.Closing = true
+// getBlockData decodes the events of a block and maps them to rows of event tables
+func (c *Consumer) getBlockData(parser *sqlsol.Parser, systemTables *sqlsol.SystemTables, resp *blockEvents) (*sqlsol.BlockData, error) {
+	// a fresh new structure to store block data
+	blockData := sqlsol.NewBlockData()
+
+	// store block number
+	blockData.SetBlockID(fmt.Sprintf("%v", resp.Height))
+
+	// blocks are stored (if requested) in the blocks table
+	if systemTables.StoresBlocks() {
+		row, err := systemTables.GetBlockRow(resp.Height, resp.BlockHeader)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error mapping block to SQL row")
+		}
+		blockData.AddRow(sqlsol.SystemTableBlocks, row)
+	}
+
+	// block time is stamped (if requested) on every event row
+	blockTime := ""
+	if c.Config.BlockTime {
+		header, err := sqlsol.DecodeBlockHeader(resp.BlockHeader)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error decoding block header")
+		}
+		if header != nil {
+			blockTime = sqlsol.FormatBlockTime(header.Time)
+		}
+	}
+
+	// transactions are stored (if requested) in the transactions table
+	for _, txe := range resp.TxExecutions {
+		row, err := systemTables.GetTxRow(txe)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error mapping transaction to SQL row")
+		}
+		blockData.AddRow(sqlsol.SystemTableTransactions, row)
+	}
+
+	// get event data
+	for _, event := range resp.Events {
+		// GetHeader gets Header data for the given event
+		// GetLog gets log event data for the given event
+		eventHeader := event.GetHeader()
+		eventLog := event.GetLog()
+
+		// exceptions are set on transactions, not on their events
+		txException := resp.TxExceptions[string(eventHeader.TxHash)]
+
+		// non log events are stored in system tables (if enabled),
+		// unless their transaction has an exception
+		if eventLog == nil {
+			if txException != nil {
+				continue
+			}
+
+			tableName, row, ok, err := systemTables.GetEventRow(event)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error mapping system event to SQL row")
+			}
+			if ok {
+				blockData.AddRow(tableName, row)
+			}
+			continue
+		}
+
+		// match the log with event specifications (logs from unknown events are skipped)
+		for _, eventName := range parser.GetEventNames(eventLog) {
+			eventSpec, err := parser.GetEventSpec(eventName)
+			if err != nil {
+				return nil, err
+			}
+
+			// skip events from other contracts or not matching the event filter
+			if !eventSpec.Matches(event) {
+				c.Log.Debug("msg", "Skipping event not matching filter", "value", eventName)
+				continue
+			}
+
+			// decode event data using the event specification and provided event log decoders,
+			// logs which can not be decoded (i.e. emitted by contracts with other event layouts) are skipped
+			eventData, err := DecodeEvent(eventHeader, eventLog, eventSpec, c.EventLogDecoders)
+			if err != nil {
+				c.Log.Warn("msg", "Skipping event which can not be decoded", "value", eventName, "height", eventHeader.GetHeight(), "err", err)
+				continue
+			}
+
+			if blockTime != "" {
+				eventData[sqlsol.BlockTimeKey] = blockTime
+			}
+
+			if txException != nil && eventData[sqlsol.ExceptionKey] == "" {
+				eventData[sqlsol.ExceptionKey] = txException.Error()
+			}
+
+			// maps event data to rows of the event table and its child tables (if any)
+			eventRows, err := parser.GetEventRows(eventName, eventData)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error mapping event data to SQL rows")
+			}
+
+			// set rows in structure
+			for tableName, rows := range eventRows {
+				for _, row := range rows {
+					blockData.AddRow(tableName, row)
+				}
+			}
+		}
+	}
+
+	return blockData, nil
 }
 
 // readFile opens a given file and reads it contents into a stream of bytes
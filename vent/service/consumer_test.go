@@ -4,7 +4,10 @@ package service_test
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"github.com/monax/bosmarmot/vent/config"
 	"github.com/monax/bosmarmot/vent/logger"
 	"github.com/monax/bosmarmot/vent/service"
+	"github.com/monax/bosmarmot/vent/sqldb"
 	"github.com/monax/bosmarmot/vent/test"
 	"github.com/stretchr/testify/require"
 )
@@ -25,11 +29,6 @@ var inputAccount = privateAccounts[0]
 var testConfig = integration.NewTestConfig(genesisDoc)
 var kern *core.Kernel
 
-func testEventLogDecoder(log *exec.LogEvent, data map[string]string) {
-	data["name"] = strings.Trim(log.Topics[2].String(), "\x00")
-	data["description"] = strings.Trim(log.Topics[3].String(), "\x00")
-}
-
 func TestMain(m *testing.M) {
 	cleanup := integration.EnterTestDirectory()
 	defer cleanup()
@@ -88,12 +87,10 @@ func TestRun(t *testing.T) {
 
 	log := logger.NewLogger(cfg.LogLevel)
 
-	// add event decoder for specific event name
-	// this will be no longer needed once event name can be standardize
+	// event inputs are decoded from log topics and data using the events config file
 	consumer := service.NewConsumer(cfg, log)
-	consumer.AddEventLogDecoder("TEST_EVENTS", testEventLogDecoder)
 
-	err := consumer.Run()
+	err := consumer.Run(context.Background())
 	require.NoError(t, err)
 
 	// test data stored in database for two different block ids
@@ -129,4 +126,656 @@ func TestRun(t *testing.T) {
 	require.Equal(t, "TEST_EVENTS", tblData[0]["eventname"])
 	require.Equal(t, "TestEvent4", tblData[0]["testname"])
 	require.Equal(t, "Description of TestEvent4", tblData[0]["testdescription"])
+
+	// consumer resumes after the last checkpointed block, so processed blocks are not logged again
+	lastBlockID, err := db.GetLastBlockID(context.Background())
+	require.NoError(t, err)
+
+	checkpoint, found, err := db.GetCheckpoint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, true, found)
+
+	consumer = service.NewConsumer(cfg, log)
+
+	err = consumer.Run(context.Background())
+	require.NoError(t, err)
+
+	blockID, err = db.GetLastBlockID(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, lastBlockID, blockID)
+
+	blockID, _, err = db.GetCheckpoint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, checkpoint, blockID)
+}
+
+func TestRunFollow(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer to follow events
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+	cfg.Follow = true
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+
+	// events of blocks produced after the consumer reached the latest block are stored too
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent5", "Description of TestEvent5")
+	blockID := fmt.Sprintf("%v", txe.Height)
+
+	// wait until the block is stored
+	for i := 0; i < 100; i++ {
+		if eventData, err := db.GetBlock(blockID); err == nil && len(eventData.Tables) > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	eventData, err := db.GetBlock(blockID)
+	require.NoError(t, err)
+
+	tblData := eventData.Tables[strings.ToLower("EventTest")]
+	require.Equal(t, blockID, tblData[0]["height"])
+	require.Equal(t, "TestEvent5", tblData[0]["testname"])
+	require.Equal(t, "Description of TestEvent5", tblData[0]["testdescription"])
+
+	// consumer stops following events once the context is cancelled
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("consumer did not stop following events")
+	}
+}
+
+func TestRunReload(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// events config file to be changed while running
+	byteValue, err := ioutil.ReadFile(os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json")
+	require.NoError(t, err)
+
+	cfgFile, err := ioutil.TempFile("", "sqlsol")
+	require.NoError(t, err)
+	defer os.Remove(cfgFile.Name())
+	cfgFile.Close()
+
+	require.NoError(t, ioutil.WriteFile(cfgFile.Name(), byteValue, 0644))
+
+	// Run consumer to follow events
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = cfgFile.Name()
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+	cfg.Follow = true
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+
+	// waits until the event of the transaction is stored in the given table
+	requireEvent := func(txe *exec.TxExecution, tableName, name string) {
+		blockID := fmt.Sprintf("%v", txe.Height)
+
+		for i := 0; i < 100; i++ {
+			if eventData, err := db.GetBlock(blockID); err == nil && len(eventData.Tables) > 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		eventData, err := db.GetBlock(blockID)
+		require.NoError(t, err)
+
+		tblData := eventData.Tables[strings.ToLower(tableName)]
+		require.Equal(t, 1, len(tblData))
+		require.Equal(t, name, tblData[0]["testname"])
+	}
+
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEventReload1", "Description of TestEventReload1")
+	requireEvent(txe, "EventTest", "TestEventReload1")
+
+	// a broken events config file is rejected, the current one is kept
+	require.NoError(t, ioutil.WriteFile(cfgFile.Name(), []byte("{"), 0644))
+	consumer.Reload()
+
+	txe = test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEventReload2", "Description of TestEventReload2")
+	requireEvent(txe, "EventTest", "TestEventReload2")
+
+	// events are stored with the new mapping once reloaded
+	newByteValue := strings.Replace(string(byteValue), `"EventTest"`, `"EventTestReloaded"`, 1)
+	require.NoError(t, ioutil.WriteFile(cfgFile.Name(), []byte(newByteValue), 0644))
+	consumer.Reload()
+
+	txe = test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEventReload3", "Description of TestEventReload3")
+	requireEvent(txe, "EventTestReloaded", "TestEventReload3")
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("consumer did not stop following events")
+	}
+}
+
+func TestRunReconnect(t *testing.T) {
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer against a node which is not available
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = "localhost:1"
+	cfg.GRPCMaxRetries = 2
+	cfg.GRPCRetryBackoff = 10 * time.Millisecond
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	// consumer gives up after the maximum number of reconnection attempts
+	err := consumer.Run(context.Background())
+	require.Error(t, err)
+
+	status := consumer.Status()
+	require.Equal(t, false, status.Connected)
+	require.Equal(t, 3, status.ReconnectAttempts)
+	require.NotEqual(t, "", status.LastError)
+}
+
+func TestRunSystemEvents(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent6", "Description of TestEvent6")
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer storing call and input events and transactions in system tables
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+	cfg.SystemEvents = []string{"call", "input", "tx"}
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	err := consumer.Run(context.Background())
+	require.NoError(t, err)
+
+	eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+	require.NoError(t, err)
+
+	// log events are stored along with system events of the same block
+	require.Equal(t, 1, len(eventData.Tables[strings.ToLower("EventTest")]))
+
+	calls := eventData.Tables["_calls"]
+	require.NotEqual(t, 0, len(calls))
+	require.Equal(t, "CallEvent", calls[0]["eventtype"])
+	require.Equal(t, create.Receipt.ContractAddress.String(), calls[0]["callee"])
+
+	inputs := eventData.Tables["_inputs"]
+	require.Equal(t, 1, len(inputs))
+	require.Equal(t, inputAccount.Address().String(), inputs[0]["address"])
+
+	// event rows can be joined with transactions by hash
+	transactions := eventData.Tables["_transactions"]
+	require.Equal(t, 1, len(transactions))
+	require.Equal(t, "CallTx", transactions[0]["txtype"])
+	require.Equal(t, `["`+inputAccount.Address().String()+`"]`, transactions[0]["signers"])
+	require.Equal(t, eventData.Tables[strings.ToLower("EventTest")][0]["txhash"], transactions[0]["txhash"])
+}
+
+func TestRunBlocks(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent7", "Description of TestEvent7")
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer storing blocks and stamping block times on event rows
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+	cfg.SystemEvents = []string{"block"}
+	cfg.BlockTime = true
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	err := consumer.Run(context.Background())
+	require.NoError(t, err)
+
+	eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+	require.NoError(t, err)
+
+	blocks := eventData.Tables["_blocks"]
+	require.Equal(t, 1, len(blocks))
+	require.Equal(t, fmt.Sprintf("%v", txe.Height), blocks[0]["height"])
+	require.Equal(t, "1", blocks[0]["numtxs"])
+	require.NotEqual(t, "", blocks[0]["blocktime"])
+	require.NotEqual(t, "", blocks[0]["apphash"])
+
+	// event rows carry the time of their block
+	events := eventData.Tables[strings.ToLower("EventTest")]
+	require.Equal(t, 1, len(events))
+	require.Equal(t, blocks[0]["blocktime"], events[0]["blocktime"])
+}
+
+func TestReindex(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent8", "Description of TestEvent8")
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer to fill the schema
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+
+	log := logger.NewLogger(cfg.LogLevel)
+
+	err := service.NewConsumer(cfg, log).Run(context.Background())
+	require.NoError(t, err)
+
+	// a consumer keeps following events while reindexing
+	followCfg := *cfg
+	followCfg.Follow = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- service.NewConsumer(&followCfg, log).Run(ctx)
+	}()
+
+	// Reindex the schema storing block times too
+	cfg.BlockTime = true
+
+	reindexer := service.NewReindexer(cfg, log)
+
+	err = reindexer.Run(context.Background())
+	require.NoError(t, err)
+
+	// the reindexed schema replaces the schema, which is kept as backup
+	backup, err := sqldb.NewSQLDB(cfg.DBAdapter, cfg.DBURL, reindexer.BackupSchema(), log)
+	require.NoError(t, err)
+	defer func() {
+		backup.DestroySchema()
+		backup.Close()
+	}()
+
+	found, err := backup.SchemaExists(reindexer.ShadowSchema())
+	require.NoError(t, err)
+	require.Equal(t, false, found)
+
+	swapped, err := sqldb.NewSQLDB(cfg.DBAdapter, cfg.DBURL, cfg.DBSchema, log)
+	require.NoError(t, err)
+	defer swapped.Close()
+
+	eventData, err := swapped.GetBlock(fmt.Sprintf("%v", txe.Height))
+	require.NoError(t, err)
+
+	events := eventData.Tables[strings.ToLower("EventTest")]
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "TestEvent8", events[0]["testname"])
+	require.NotEqual(t, "", events[0]["blocktime"])
+
+	eventData, err = backup.GetBlock(fmt.Sprintf("%v", txe.Height))
+	require.NoError(t, err)
+
+	events = eventData.Tables[strings.ToLower("EventTest")]
+	require.Equal(t, 1, len(events))
+
+	_, ok := events[0]["blocktime"]
+	require.Equal(t, false, ok)
+
+	// the following consumer goes on consuming events into the reindexed schema
+	txe = test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent9", "Description of TestEvent9")
+	blockID := fmt.Sprintf("%v", txe.Height)
+
+	for i := 0; i < 100; i++ {
+		if eventData, err = swapped.GetBlock(blockID); err == nil && len(eventData.Tables) > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	eventData, err = swapped.GetBlock(blockID)
+	require.NoError(t, err)
+
+	events = eventData.Tables[strings.ToLower("EventTest")]
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "TestEvent9", events[0]["testname"])
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("consumer did not stop following events")
+	}
+}
+
+func TestRunChains(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+	txe := test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, "TestEvent9", "Description of TestEvent9")
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumers of a chain and of a misconfigured one
+	cfg := config.DefaultFlags()
+	chains := []config.Chain{
+		{
+			Name:     "good",
+			GRPCAddr: testConfig.RPC.GRPC.ListenAddress,
+			DBSchema: db.Schema,
+			CfgFile:  os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json",
+		},
+		{
+			Name:     "bad",
+			GRPCAddr: testConfig.RPC.GRPC.ListenAddress,
+			DBSchema: db.Schema + "_bad",
+			CfgFile:  "missing.json",
+		},
+	}
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewMultiConsumer(cfg, chains, log)
+
+	err := consumer.Run(context.Background())
+	require.Error(t, err)
+
+	// the failing chain does not stop the other one
+	status := consumer.Status()
+	require.Equal(t, "", status["good"].Error)
+	require.NotEqual(t, "", status["bad"].Error)
+
+	eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(eventData.Tables[strings.ToLower("EventTest")]))
+}
+
+func TestRunCatchUp(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+
+	var txes []*exec.TxExecution
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("TestEventCatchUp%d", i)
+		txes = append(txes, test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, name, "Description of "+name))
+	}
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	// create test db
+	db, closeDB := test.NewTestDB(t)
+	defer closeDB()
+
+	// Run consumer catching up with several workers fetching small ranges
+	cfg := config.DefaultFlags()
+
+	cfg.DBSchema = db.Schema
+	cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+	cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+	cfg.CatchUpWorkers = 3
+	cfg.CatchUpRange = 2
+
+	log := logger.NewLogger(cfg.LogLevel)
+	consumer := service.NewConsumer(cfg, log)
+
+	err := consumer.Run(context.Background())
+	require.NoError(t, err)
+
+	// every block is committed in order
+	for i, txe := range txes {
+		eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+		require.NoError(t, err)
+
+		tblData := eventData.Tables[strings.ToLower("EventTest")]
+		require.Equal(t, 1, len(tblData))
+		require.Equal(t, fmt.Sprintf("TestEventCatchUp%d", i), tblData[0]["testname"])
+	}
+
+	checkpoint, found, err := db.GetCheckpoint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, true, found)
+
+	height, err := strconv.ParseUint(checkpoint, 10, 64)
+	require.NoError(t, err)
+	require.True(t, height >= txes[len(txes)-1].Height)
+}
+
+func TestRunBatch(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+
+	var txes []*exec.TxExecution
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("TestEventBatch%d", i)
+		txes = append(txes, test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, name, "Description of "+name))
+	}
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("commits batches of blocks with %d catch-up workers", workers), func(t *testing.T) {
+			// create test db
+			db, closeDB := test.NewTestDB(t)
+			defer closeDB()
+
+			cfg := config.DefaultFlags()
+
+			cfg.DBSchema = db.Schema
+			cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+			cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+			cfg.CatchUpWorkers = workers
+			cfg.CatchUpRange = 2
+			cfg.BatchBlocks = 3
+
+			log := logger.NewLogger(cfg.LogLevel)
+			consumer := service.NewConsumer(cfg, log)
+
+			err := consumer.Run(context.Background())
+			require.NoError(t, err)
+
+			// log info is still stored for every block
+			for i, txe := range txes {
+				eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+				require.NoError(t, err)
+
+				tblData := eventData.Tables[strings.ToLower("EventTest")]
+				require.Equal(t, 1, len(tblData))
+				require.Equal(t, fmt.Sprintf("TestEventBatch%d", i), tblData[0]["testname"])
+			}
+
+			checkpoint, found, err := db.GetCheckpoint(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, true, found)
+
+			height, err := strconv.ParseUint(checkpoint, 10, 64)
+			require.NoError(t, err)
+			require.True(t, height >= txes[len(txes)-1].Height)
+		})
+	}
+}
+
+func TestRunHeights(t *testing.T) {
+	tCli := test.NewTransactClient(t, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(t, tCli, inputAccount.Address())
+
+	var txes []*exec.TxExecution
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("TestEventHeights%d", i)
+		txes = append(txes, test.CallAddEvent(t, tCli, inputAccount.Address(), create.Receipt.ContractAddress, name, "Description of "+name))
+	}
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	t.Run("successfully consumes a window of blocks", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		cfg := config.DefaultFlags()
+
+		cfg.DBSchema = db.Schema
+		cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+		cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+		cfg.FromHeight = txes[1].Height
+		cfg.ToHeight = txes[2].Height
+
+		log := logger.NewLogger(cfg.LogLevel)
+
+		err := service.NewConsumer(cfg, log).Run(context.Background())
+		require.NoError(t, err)
+
+		// only blocks in the window are stored
+		for i, txe := range txes {
+			eventData, err := db.GetBlock(fmt.Sprintf("%v", txe.Height))
+			require.NoError(t, err)
+
+			tblData := eventData.Tables[strings.ToLower("EventTest")]
+			if i == 1 || i == 2 {
+				require.Equal(t, 1, len(tblData))
+				require.Equal(t, fmt.Sprintf("TestEventHeights%d", i), tblData[0]["testname"])
+			} else {
+				require.Equal(t, 0, len(tblData))
+			}
+		}
+
+		checkpoint, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, fmt.Sprintf("%v", txes[2].Height), checkpoint)
+	})
+
+	t.Run("consumes no block within the confirmation lag", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		cfg := config.DefaultFlags()
+
+		cfg.DBSchema = db.Schema
+		cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+		cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+		cfg.ConfirmationLag = txes[len(txes)-1].Height + 1000
+
+		log := logger.NewLogger(cfg.LogLevel)
+
+		err := service.NewConsumer(cfg, log).Run(context.Background())
+		require.NoError(t, err)
+
+		_, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, false, found)
+	})
+}
+
+func BenchmarkRunCatchUp(b *testing.B) {
+	tCli := test.NewTransactClient(b, testConfig.RPC.GRPC.ListenAddress)
+	create := test.CreateContract(b, tCli, inputAccount.Address())
+
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("BenchmarkEvent%d", i)
+		test.CallAddEvent(b, tCli, inputAccount.Address(), create.Receipt.ContractAddress, name, "Description of "+name)
+	}
+
+	// This is a workaround for off-by-one on latest bound fixed in burrow
+	time.Sleep(time.Second * 2)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			cfg := config.DefaultFlags()
+
+			cfg.CfgFile = os.Getenv("GOPATH") + "/src/github.com/monax/bosmarmot/vent/test/sqlsol_example.json"
+			cfg.GRPCAddr = testConfig.RPC.GRPC.ListenAddress
+			cfg.LogLevel = "none"
+			cfg.CatchUpWorkers = workers
+			cfg.CatchUpRange = 10
+
+			log := logger.NewLogger(cfg.LogLevel)
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db, closeDB := test.NewTestDB(b)
+				cfg.DBSchema = db.Schema
+				b.StartTimer()
+
+				err := service.NewConsumer(cfg, log).Run(context.Background())
+
+				b.StopTimer()
+				closeDB()
+				require.NoError(b, err)
+				b.StartTimer()
+			}
+		})
+	}
 }
@@ -1,35 +1,261 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
-	"strings"
+	"math/big"
+	"strconv"
 
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/execution/evm/abi"
 	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/types"
 )
 
 type EventLogDecoder func(*exec.LogEvent, map[string]string)
 
 // DecodeEvent decodes standard & non standard event data
-// using provided logDecoders
-func DecodeEvent(header *exec.Header, log *exec.LogEvent, logDecoders map[string]EventLogDecoder) map[string]string {
+// using the given event specification and provided logDecoders
+func DecodeEvent(header *exec.Header, log *exec.LogEvent, eventSpec sqlsol.EventSpec, logDecoders map[string]EventLogDecoder) (map[string]string, error) {
 	data := make(map[string]string)
 
-	// decode header
+	// decode event inputs from log topics and data
+	if err := decodeLog(eventSpec.Event, log, data); err != nil {
+		return nil, fmt.Errorf("DecodeEvent: can not decode log for event %s: %v", eventSpec.Name, err)
+	}
+
+	data["eventName"] = eventSpec.Name
+
+	if logDecoder, ok := logDecoders[eventSpec.Name]; ok {
+		logDecoder(log, data)
+	}
+
+	// decode header (event inputs can not override header data)
 	data["index"] = fmt.Sprintf("%v", header.GetIndex())
 	data["height"] = fmt.Sprintf("%v", header.GetHeight())
 	data["eventType"] = header.GetEventType().String()
 	data["txHash"] = string(header.TxHash)
+	data["contractAddress"] = log.Address.String()
+
+	// events of executions with exceptions are stored depending on the event exception policy
+	if header.Exception != nil {
+		data[sqlsol.ExceptionKey] = header.Exception.Error()
+	}
+
+	return data, nil
+}
+
+// decodeLog unpacks indexed event inputs from log topics
+// and non indexed event inputs from log data
+func decodeLog(event types.Event, log *exec.LogEvent, data map[string]string) error {
+	// non anonymous events store the event signature in the first topic
+	topic := 0
+	if !event.Anonymous {
+		topic++
+	}
+
+	var dataInputs []types.EventInput
+	var dataTypes []types.EventInputType
+
+	for _, input := range event.Inputs {
+		evType, err := input.ParseType()
+		if err != nil {
+			return err
+		}
+
+		if !input.Indexed {
+			dataInputs = append(dataInputs, input)
+			dataTypes = append(dataTypes, evType)
+			continue
+		}
+
+		if topic >= len(log.Topics) {
+			return fmt.Errorf("expected topic %d for input %s, log has %d topics", topic, input.Name, len(log.Topics))
+		}
+
+		if evType.IsValueType() {
+			value, err := decodeValue(evType, log.Topics[topic].Bytes())
+			if err != nil {
+				return fmt.Errorf("can not decode input %s: %v", input.Name, err)
+			}
+			data[input.Name] = formatValue(value)
+		} else {
+			// only the keccak256 hash of other indexed values is stored
+			data[input.Name] = binary.HexBytes(log.Topics[topic].Bytes()).String()
+		}
+		topic++
+	}
+
+	values, err := decodeValues(dataTypes, log.Data)
+	if err != nil {
+		return err
+	}
+
+	for i, input := range dataInputs {
+		data[input.Name] = formatValue(values[i])
+	}
+
+	return nil
+}
+
+// decodeValues decodes a sequence of ABI encoded values,
+// static values are stored in the head and dynamic values
+// are referenced from the head by their offset
+func decodeValues(evTypes []types.EventInputType, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(evTypes))
+	head := 0
+
+	for i, evType := range evTypes {
+		var err error
+
+		if evType.IsDynamic() {
+			offset, err := decodeLength(data, head)
+			if err != nil {
+				return nil, err
+			}
+			if offset > len(data) {
+				return nil, fmt.Errorf("offset %d out of data bounds (%d bytes)", offset, len(data))
+			}
+			values[i], err = decodeValue(evType, data[offset:])
+			if err != nil {
+				return nil, err
+			}
+			head += abi.ElementSize
+			continue
+		}
+
+		if head+evType.HeadSize() > len(data) {
+			return nil, fmt.Errorf("expected at least %d bytes of data, got %d", head+evType.HeadSize(), len(data))
+		}
+		values[i], err = decodeValue(evType, data[head:])
+		if err != nil {
+			return nil, err
+		}
+		head += evType.HeadSize()
+	}
+
+	return values, nil
+}
+
+// decodeValue decodes an ABI encoded value, returns strings for basic types,
+// slices for arrays and maps (by component name) for tuples
+func decodeValue(evType types.EventInputType, data []byte) (interface{}, error) {
+	if evType.IsArray() {
+		length := evType.ArrayLength()
+		if length == 0 {
+			var err error
+			if length, err = decodeLength(data, 0); err != nil {
+				return nil, err
+			}
+			data = data[abi.ElementSize:]
+		}
+
+		// every element takes at least a word
+		if length > len(data)/abi.ElementSize {
+			return nil, fmt.Errorf("array of %d elements out of data bounds (%d bytes)", length, len(data))
+		}
+
+		elemTypes := make([]types.EventInputType, length)
+		for i := range elemTypes {
+			elemTypes[i] = evType.Elem()
+		}
+		return decodeValues(elemTypes, data)
+	}
+
+	if evType.IsTuple() {
+		componentTypes := make([]types.EventInputType, len(evType.Components))
+		for i, component := range evType.Components {
+			componentType, err := component.ParseType()
+			if err != nil {
+				return nil, err
+			}
+			componentTypes[i] = componentType
+		}
+
+		values, err := decodeValues(componentTypes, data)
+		if err != nil {
+			return nil, err
+		}
+
+		tuple := make(map[string]interface{}, len(values))
+		for i, component := range evType.Components {
+			tuple[component.Name] = values[i]
+		}
+		return tuple, nil
+	}
 
-	// decode data log
-	if len(log.Topics) > 1 {
-		eventName := strings.Trim(log.Topics[1].String(), "\x00")
+	if len(data) < abi.ElementSize {
+		return nil, fmt.Errorf("expected at least %d bytes of data, got %d", abi.ElementSize, len(data))
+	}
+	word := data[:abi.ElementSize]
 
-		data["eventName"] = eventName
+	switch evType.Base {
+	case types.EventInputTypeUInt:
+		return new(big.Int).SetBytes(word).String(), nil
+	case types.EventInputTypeInt:
+		// two's complement
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), abi.ElementSize*8))
+		}
+		return value.String(), nil
+	case types.EventInputTypeAddress:
+		address, err := crypto.AddressFromBytes(word[abi.ElementSize-crypto.AddressLength:])
+		if err != nil {
+			return nil, err
+		}
+		return address.String(), nil
+	case types.EventInputTypeBool:
+		return strconv.FormatBool(word[abi.ElementSize-1] != 0), nil
+	case types.EventInputTypeBytes, types.EventInputTypeString:
+		if evType.Size > 0 {
+			return binary.HexBytes(word[:evType.Size]).String(), nil
+		}
 
-		if logDecoder, ok := logDecoders[eventName]; ok {
-			logDecoder(log, data)
+		length, err := decodeLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		if length > len(data)-abi.ElementSize {
+			return nil, fmt.Errorf("%s of %d bytes out of data bounds (%d bytes)", evType.Base, length, len(data))
 		}
+
+		value := data[abi.ElementSize : abi.ElementSize+length]
+		if evType.Base == types.EventInputTypeString {
+			return string(value), nil
+		}
+		return binary.HexBytes(value).String(), nil
+	default:
+		return nil, fmt.Errorf("can not decode type %s", evType.Canonical())
 	}
+}
 
-	return data
+// decodeLength decodes a length (or an offset) stored in the word at the given position
+func decodeLength(data []byte, position int) (int, error) {
+	if position+abi.ElementSize > len(data) {
+		return 0, fmt.Errorf("expected at least %d bytes of data, got %d", position+abi.ElementSize, len(data))
+	}
+
+	length := new(big.Int).SetBytes(data[position : position+abi.ElementSize])
+	if !length.IsInt64() || length.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("length %s out of data bounds (%d bytes)", length, len(data))
+	}
+
+	return int(length.Int64()), nil
+}
+
+// formatValue returns the string representation of a decoded value,
+// arrays and tuples are formatted as JSON
+func formatValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(bytes)
 }
@@ -0,0 +1,320 @@
+package service_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/execution/errors"
+	"github.com/hyperledger/burrow/execution/evm/abi"
+	"github.com/hyperledger/burrow/execution/evm/sha3"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	goodJSON := test.GoodJSONConfFile(t)
+
+	byteValue := []byte(goodJSON)
+	parser, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	eventSpec, err := parser.GetEventSpec("TEST_EVENTS")
+	require.NoError(t, err)
+
+	header := &exec.Header{
+		TxHash:    []byte("hash"),
+		EventType: exec.TypeLog,
+		Height:    99,
+		Index:     1,
+	}
+
+	t.Run("successfully decodes non indexed event inputs from log data", func(t *testing.T) {
+		data, err := abi.Pack([]abi.Argument{
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMUint{M: 256}},
+		}, "TestEvent1", "Description of TestEvent1", 42)
+		require.NoError(t, err)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			Data:   data,
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.NoError(t, err)
+		require.Equal(t, "TEST_EVENTS", eventData["eventName"])
+		require.Equal(t, "TestEvent1", eventData["name"])
+		require.Equal(t, "Description of TestEvent1", eventData["description"])
+		require.Equal(t, "42", eventData["UnimportantInfo"])
+		require.Equal(t, "99", eventData["height"])
+		require.Equal(t, "1", eventData["index"])
+		require.Equal(t, "LogEvent", eventData["eventType"])
+
+		_, ok := eventData[sqlsol.ExceptionKey]
+		require.Equal(t, false, ok)
+	})
+
+	t.Run("successfully decodes the exception of the event execution", func(t *testing.T) {
+		data, err := abi.Pack([]abi.Argument{
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMUint{M: 256}},
+		}, "TestEvent1", "Description of TestEvent1", 42)
+		require.NoError(t, err)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			Data:   data,
+		}
+
+		exceptionHeader := *header
+		exceptionHeader.Exception = errors.ErrorCodef(errors.ErrorCodeExecutionReverted, "reverted")
+
+		eventData, err := service.DecodeEvent(&exceptionHeader, log, eventSpec, nil)
+		require.NoError(t, err)
+		require.Equal(t, exceptionHeader.Exception.Error(), eventData[sqlsol.ExceptionKey])
+	})
+
+	t.Run("successfully decodes indexed event inputs from log topics", func(t *testing.T) {
+		indexedJSON := test.IndexedInputsJSONConfFile(t)
+
+		parser, err := sqlsol.NewParser([]byte(indexedJSON))
+		require.NoError(t, err)
+
+		eventSpec, err := parser.GetEventSpec("TEST_EVENTS")
+		require.NoError(t, err)
+
+		data, err := abi.Pack([]abi.Argument{{EVM: abi.EVMUint{M: 256}}}, 7)
+		require.NoError(t, err)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{
+				{},
+				binary.RightPadWord256([]byte("TEST_EVENTS")),
+				binary.RightPadWord256([]byte("TestEvent1")),
+				binary.RightPadWord256([]byte("Description of TestEvent1")),
+			},
+			Data: data,
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.NoError(t, err)
+		// bytes32 values are decoded as hex strings
+		require.Equal(t, "544553545F4556454E5453000000000000000000000000000000000000000000", eventData["name"])
+		require.Equal(t, "546573744576656E743100000000000000000000000000000000000000000000", eventData["key"])
+		require.Equal(t, "4465736372697074696F6E206F6620546573744576656E743100000000000000", eventData["description"])
+		require.Equal(t, "7", eventData["UnimportantInfo"])
+	})
+
+	t.Run("applies provided event log decoders after decoding event inputs", func(t *testing.T) {
+		data, err := abi.Pack([]abi.Argument{
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMUint{M: 256}},
+		}, "TestEvent1", "Description of TestEvent1", 42)
+		require.NoError(t, err)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			Data:   data,
+		}
+
+		logDecoders := map[string]service.EventLogDecoder{
+			"TEST_EVENTS": func(log *exec.LogEvent, data map[string]string) {
+				data["description"] = "overridden"
+			},
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, logDecoders)
+		require.NoError(t, err)
+		require.Equal(t, "TestEvent1", eventData["name"])
+		require.Equal(t, "overridden", eventData["description"])
+	})
+
+	t.Run("successfully decodes sized, array and tuple event inputs", func(t *testing.T) {
+		typesJSON := test.TypesJSONConfFile(t)
+
+		parser, err := sqlsol.NewParser([]byte(typesJSON))
+		require.NoError(t, err)
+
+		eventSpec, err := parser.GetEventSpec("UpdateTypes")
+		require.NoError(t, err)
+
+		maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+		tagsHash := binary.LeftPadWord256(sha3.Sha3([]byte("tag")))
+
+		var data []byte
+		// head
+		data = append(data, word(maxUint256)...)
+		data = append(data, word(big.NewInt(-42))...)
+		data = append(data, binary.RightPadWord256([]byte("ID")).Bytes()...)
+		data = append(data, word(big.NewInt(1))...)
+		data = append(data, word(big.NewInt(2))...)
+		data = append(data, word(big.NewInt(7*32))...)
+		data = append(data, word(big.NewInt(14*32))...)
+		// names
+		data = append(data, word(big.NewInt(2))...)
+		data = append(data, word(big.NewInt(2*32))...)
+		data = append(data, word(big.NewInt(4*32))...)
+		data = append(data, word(big.NewInt(2))...)
+		data = append(data, binary.RightPadWord256([]byte("ab")).Bytes()...)
+		data = append(data, word(big.NewInt(3))...)
+		data = append(data, binary.RightPadWord256([]byte("cde")).Bytes()...)
+		// owner
+		data = append(data, binary.LeftPadWord256([]byte{0x4A, 0xE3}).Bytes()...)
+		data = append(data, word(big.NewInt(1))...)
+		data = append(data, word(big.NewInt(3*32))...)
+		data = append(data, word(big.NewInt(2))...)
+		data = append(data, binary.RightPadWord256([]byte{0xBE, 0xEF}).Bytes()...)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{eventSpec.ID, binary.LeftPadWord256(word(big.NewInt(-5))), tagsHash},
+			Data:   data,
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.NoError(t, err)
+		require.Equal(t, "-5", eventData["small"])
+		require.Equal(t, binary.HexBytes(tagsHash.Bytes()).String(), eventData["tags"])
+		require.Equal(t, maxUint256.String(), eventData["amount"])
+		require.Equal(t, "-42", eventData["balance"])
+		require.Equal(t, "4944000000000000000000000000000000000000000000000000000000000000", eventData["id"])
+		require.Equal(t, `["1","2"]`, eventData["values"])
+		require.Equal(t, `["ab","cde"]`, eventData["names"])
+		require.Equal(t, `{"account":"0000000000000000000000000000000000004AE3","active":"true","data":"BEEF"}`, eventData["owner"])
+	})
+
+	t.Run("successfully decodes dynamic arrays nested in tuples", func(t *testing.T) {
+		eventSpec, data := nestedTypesEvent(t)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{eventSpec.ID},
+			Data:   data,
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.NoError(t, err)
+		require.Equal(t, `{"id":"7","names":["ab","cde"]}`, eventData["group"])
+		require.Equal(t, "BEEF", eventData["payload"])
+	})
+
+	t.Run("returns an error if offsets or lengths are out of log data bounds", func(t *testing.T) {
+		eventSpec, data := nestedTypesEvent(t)
+
+		maxInt64 := big.NewInt(math.MaxInt64)
+		maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+		outOfBounds := []*big.Int{
+			big.NewInt(int64(len(data))),
+			new(big.Int).Sub(maxInt64, big.NewInt(31)),
+			maxInt64,
+			new(big.Int).Add(maxInt64, big.NewInt(1)),
+			new(big.Int).Lsh(big.NewInt(1), 64),
+			maxUint256,
+		}
+
+		// positions of the words holding offsets and lengths
+		positions := map[string]int{
+			"tuple offset":                0,
+			"bytes offset":                1,
+			"nested array offset":         3,
+			"nested array length":         4,
+			"nested array element offset": 5,
+			"nested string length":        7,
+			"bytes length":                11,
+		}
+
+		for name, position := range positions {
+			for _, value := range outOfBounds {
+				badData := make([]byte, len(data))
+				copy(badData, data)
+				copy(badData[position*32:], word(value))
+
+				log := &exec.LogEvent{
+					Topics: []binary.Word256{eventSpec.ID},
+					Data:   badData,
+				}
+
+				_, err := service.DecodeEvent(header, log, eventSpec, nil)
+				require.Error(t, err, "%s %s", name, value)
+			}
+		}
+	})
+
+	t.Run("successfully decodes anonymous event inputs from all log topics", func(t *testing.T) {
+		anonymousJSON := test.AnonymousJSONConfFile(t)
+
+		parser, err := sqlsol.NewParser([]byte(anonymousJSON))
+		require.NoError(t, err)
+
+		eventSpec, err := parser.GetEventSpec("Transfer")
+		require.NoError(t, err)
+
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{
+				binary.LeftPadWord256([]byte{0x01}),
+				binary.LeftPadWord256([]byte{0x02}),
+			},
+			Data: word(big.NewInt(1000)),
+		}
+
+		eventData, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.NoError(t, err)
+		require.Equal(t, "0000000000000000000000000000000000000001", eventData["from"])
+		require.Equal(t, "0000000000000000000000000000000000000002", eventData["to"])
+		require.Equal(t, "1000", eventData["value"])
+	})
+
+	t.Run("returns an error if log data is too short for the event inputs", func(t *testing.T) {
+		log := &exec.LogEvent{
+			Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			Data:   make([]byte, 32),
+		}
+
+		_, err := service.DecodeEvent(header, log, eventSpec, nil)
+		require.Error(t, err)
+	})
+}
+
+// nestedTypesEvent returns the specification of an event with a string array nested in a tuple,
+// along with log data encoding the (7, ["ab", "cde"]) tuple and BEEF bytes
+func nestedTypesEvent(t *testing.T) (sqlsol.EventSpec, []byte) {
+	parser, err := sqlsol.NewParser([]byte(test.NestedTypesJSONConfFile(t)))
+	require.NoError(t, err)
+
+	eventSpec, err := parser.GetEventSpec("UpdateNestedTypes")
+	require.NoError(t, err)
+
+	var data []byte
+	// head
+	data = append(data, word(big.NewInt(2*32))...)
+	data = append(data, word(big.NewInt(11*32))...)
+	// group
+	data = append(data, word(big.NewInt(7))...)
+	data = append(data, word(big.NewInt(2*32))...)
+	data = append(data, word(big.NewInt(2))...)
+	data = append(data, word(big.NewInt(2*32))...)
+	data = append(data, word(big.NewInt(4*32))...)
+	data = append(data, word(big.NewInt(2))...)
+	data = append(data, binary.RightPadWord256([]byte("ab")).Bytes()...)
+	data = append(data, word(big.NewInt(3))...)
+	data = append(data, binary.RightPadWord256([]byte("cde")).Bytes()...)
+	// payload
+	data = append(data, word(big.NewInt(2))...)
+	data = append(data, binary.RightPadWord256([]byte{0xBE, 0xEF}).Bytes()...)
+
+	return eventSpec, data
+}
+
+// word returns the ABI encoding of an integer (two's complement for negative ones)
+func word(value *big.Int) []byte {
+	if value.Sign() < 0 {
+		value = new(big.Int).Add(value, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return binary.LeftPadWord256(value.Bytes()).Bytes()
+}
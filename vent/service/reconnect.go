@@ -0,0 +1,76 @@
+package service
+
+import (
+	"math/rand"
+	"time"
+)
+
+// maxReconnectBackoff caps the delay between reconnection attempts
+const maxReconnectBackoff = time.Minute
+
+// Status contains the state of the consumer connection to the events stream
+type Status struct {
+	Connected         bool
+	ReconnectAttempts int
+	Reconnects        int
+	LastError         string
+}
+
+// streamError wraps errors of the events stream, the ones recovered by reconnecting
+type streamError struct {
+	error
+}
+
+// ReconnectBackoff returns the delay before a given reconnection attempt (starting at 1),
+// doubling the base delay on each attempt up to max, half of the delay is a random jitter
+// so consumers do not reconnect at once after a node restart
+func ReconnectBackoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Status returns the state of the consumer connection to the events stream
+func (c *Consumer) Status() Status {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.status
+}
+
+// setConnected updates the status once the events stream is open
+func (c *Consumer) setConnected(connected bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.status.Connected = connected
+}
+
+// resetReconnectAttempts clears reconnection attempts once events are received
+func (c *Consumer) resetReconnectAttempts() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.status.ReconnectAttempts = 0
+}
+
+// addReconnectAttempt updates the status after an events stream error
+// and returns the number of consecutive reconnection attempts
+func (c *Consumer) addReconnectAttempt(err error) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.status.Connected = false
+	c.status.ReconnectAttempts++
+	c.status.Reconnects++
+	c.status.LastError = err.Error()
+
+	return c.status.ReconnectAttempts
+}
@@ -0,0 +1,29 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	t.Run("successfully doubles the delay on each attempt with a jitter", func(t *testing.T) {
+		base := time.Second
+
+		for attempt, delay := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 16 * time.Second} {
+			backoff := service.ReconnectBackoff(attempt, base, time.Minute)
+			require.True(t, backoff >= delay/2, "attempt %d", attempt)
+			require.True(t, backoff <= delay, "attempt %d", attempt)
+		}
+	})
+
+	t.Run("successfully caps the delay", func(t *testing.T) {
+		for _, attempt := range []int{7, 10, 100} {
+			backoff := service.ReconnectBackoff(attempt, time.Second, time.Minute)
+			require.True(t, backoff >= 30*time.Second, "attempt %d", attempt)
+			require.True(t, backoff <= time.Minute, "attempt %d", attempt)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/sqldb"
+	"github.com/pkg/errors"
+)
+
+// defined limits of attempts to swap schemas
+const (
+	maxSwapAttempts = 100
+	swapRetryDelay  = time.Second
+)
+
+// Reindexer rebuilds SQL event tables into a shadow schema while the default schema
+// keeps being written by a consumer, then swaps both schemas
+type Reindexer struct {
+	Config *config.Flags
+	Log    *logger.Logger
+}
+
+// NewReindexer constructs a new reindexer configuration
+func NewReindexer(cfg *config.Flags, log *logger.Logger) *Reindexer {
+	return &Reindexer{
+		Config: cfg,
+		Log:    log,
+	}
+}
+
+// Run replays the configured block range into a fresh shadow schema, then catches up
+// with the default schema checkpoint and swaps both schemas atomically (if requested),
+// the default schema is kept as the backup schema
+func (r *Reindexer) Run(ctx context.Context) error {
+	shadowSchema := r.ShadowSchema()
+	backupSchema := r.BackupSchema()
+
+	if shadowSchema == r.Config.DBSchema || backupSchema == r.Config.DBSchema || backupSchema == shadowSchema {
+		return errors.New("Error reindexing, schema, shadow schema and backup schema must be different")
+	}
+
+	// the shadow schema would lack rows of the blocks before the first block to consume
+	if r.Config.Swap && r.Config.FromHeight > 0 {
+		return errors.New("Error reindexing, schemas can only be swapped when reindexing from the first block (use --swap=false)")
+	}
+
+	r.Log.Info("msg", "Connecting to SQL database")
+
+	db, err := sqldb.NewSQLDB(r.Config.DBAdapter, r.Config.DBURL, r.Config.DBSchema, r.Log)
+	if err != nil {
+		return errors.Wrap(err, "Error connecting to SQL")
+	}
+	defer db.Close()
+
+	if r.Config.Swap {
+		found, err := db.SchemaExists(backupSchema)
+		if err != nil {
+			return errors.Wrap(err, "Error trying to find backup schema")
+		}
+		if found {
+			return errors.Errorf("Error reindexing, backup schema %s already exists", backupSchema)
+		}
+	}
+
+	r.Log.Info("msg", "Dropping shadow schema", "value", shadowSchema)
+
+	shadowDB, err := sqldb.NewSQLDB(r.Config.DBAdapter, r.Config.DBURL, shadowSchema, r.Log)
+	if err != nil {
+		return errors.Wrap(err, "Error connecting to SQL")
+	}
+	err = shadowDB.DestroySchema()
+	shadowDB.Close()
+	if err != nil {
+		return errors.Wrap(err, "Error dropping shadow schema")
+	}
+
+	// events are consumed into the shadow schema up to the last block of the range
+	cfg := *r.Config
+	cfg.DBSchema = shadowSchema
+	cfg.Follow = false
+
+	r.Log.Info("msg", "Replaying blocks into shadow schema", "from", cfg.FromHeight, "to", cfg.ToHeight)
+
+	if err = NewConsumer(&cfg, r.Log).Run(ctx); err != nil {
+		return errors.Wrap(err, "Error replaying blocks into shadow schema")
+	}
+
+	if !r.Config.Swap || ctx.Err() != nil {
+		return nil
+	}
+
+	shadowDB, err = sqldb.NewSQLDB(r.Config.DBAdapter, r.Config.DBURL, shadowSchema, r.Log)
+	if err != nil {
+		return errors.Wrap(err, "Error connecting to SQL")
+	}
+	defer shadowDB.Close()
+
+	// the default schema keeps moving forward, so the shadow schema catches up
+	// with its checkpoint until both schemas can be swapped (attempts are delayed and limited,
+	// so a shadow schema unable to reach the checkpoint does not keep catching up forever)
+	for attempt := 1; ctx.Err() == nil; attempt++ {
+		swapped, height, err := db.SwapSchema(ctx, shadowDB, backupSchema)
+		if err != nil {
+			return errors.Wrap(err, "Error swapping schemas")
+		}
+
+		if swapped {
+			r.Log.Info("msg", "Schemas swapped", "schema", r.Config.DBSchema, "backup", backupSchema, "height", height)
+			return nil
+		}
+
+		if attempt > maxSwapAttempts {
+			return errors.Errorf("Error swapping schemas, shadow schema did not catch up with schema checkpoint %s after %d attempts", height, maxSwapAttempts)
+		}
+
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(swapRetryDelay):
+			}
+		}
+
+		cfg.ToHeight, err = strconv.ParseUint(height, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "Error trying to convert checkpoint from string to uint64")
+		}
+
+		r.Log.Info("msg", "Catching up with schema checkpoint", "value", height)
+
+		if err = NewConsumer(&cfg, r.Log).Run(ctx); err != nil {
+			return errors.Wrap(err, "Error catching up with schema checkpoint")
+		}
+	}
+
+	return nil
+}
+
+// ShadowSchema returns the schema to reindex into (defaults to the schema with a _reindex suffix)
+func (r *Reindexer) ShadowSchema() string {
+	if r.Config.ShadowSchema != "" {
+		return r.Config.ShadowSchema
+	}
+
+	return r.Config.DBSchema + "_reindex"
+}
+
+// BackupSchema returns the schema to keep the swapped schema (defaults to the schema with an _old suffix)
+func (r *Reindexer) BackupSchema() string {
+	if r.Config.BackupSchema != "" {
+		return r.Config.BackupSchema
+	}
+
+	return r.Config.DBSchema + "_old"
+}
@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReindexer(t *testing.T) {
+	t.Run("returns an error if schemas are swapped when reindexing from a later block", func(t *testing.T) {
+		cfg := config.DefaultFlags()
+		cfg.FromHeight = 10
+		cfg.Swap = true
+
+		err := service.NewReindexer(cfg, logger.NewLogger("none")).Run(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "--swap=false")
+	})
+
+	t.Run("returns an error if the shadow schema is the schema", func(t *testing.T) {
+		cfg := config.DefaultFlags()
+		cfg.ShadowSchema = cfg.DBSchema
+
+		err := service.NewReindexer(cfg, logger.NewLogger("none")).Run(context.Background())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+
+	"github.com/monax/bosmarmot/vent/filesink"
+	"github.com/monax/bosmarmot/vent/sqldb"
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// Sink stores the rows of decoded blocks (SQL event tables by default),
+// consumers resume from the block following its checkpoint
+type Sink interface {
+	// SynchronizeDB prepares the sink to store rows of the given tables (new or changed ones)
+	SynchronizeDB(ctx context.Context, eventTables types.EventTables) error
+	// GetCheckpoint returns the last block completely stored (found is false if there is none)
+	GetCheckpoint(ctx context.Context) (height string, found bool, err error)
+	// SetBlocks stores the rows of several consecutive blocks at once and checkpoints the last one
+	// (blocks without rows only move the checkpoint forward), nothing is stored on errors
+	SetBlocks(ctx context.Context, eventTables types.EventTables, blocks []types.EventData) error
+}
+
+// logSink is a sink which can also return the last block stored before checkpoints were stored
+type logSink interface {
+	GetLastBlockID(ctx context.Context) (string, error)
+}
+
+var _ Sink = (*sqldb.SQLDB)(nil)
+var _ Sink = (*filesink.FileSink)(nil)
+var _ logSink = (*sqldb.SQLDB)(nil)
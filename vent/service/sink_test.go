@@ -0,0 +1,489 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/event/query"
+	"github.com/hyperledger/burrow/execution/evm/abi"
+	"github.com/hyperledger/burrow/execution/evm/sha3"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/hyperledger/burrow/rpc/rpcevents"
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/filesink"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/service"
+	"github.com/monax/bosmarmot/vent/sqldb"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSink(t *testing.T) {
+	cfgFile, err := ioutil.TempFile("", "sqlsol")
+	require.NoError(t, err)
+	defer os.Remove(cfgFile.Name())
+	cfgFile.Close()
+
+	require.NoError(t, ioutil.WriteFile(cfgFile.Name(), []byte(test.GoodJSONConfFile(t)), 0644))
+
+	cfg := config.DefaultFlags()
+	cfg.CfgFile = cfgFile.Name()
+	cfg.LogLevel = "none"
+
+	source := &memorySource{}
+	source.addBlock(t, 1, "TestEvent1")
+	source.addBlock(t, 2)
+	source.addBlock(t, 3, "TestEvent3")
+
+	sink := &memorySink{}
+
+	t.Run("successfully consumes blocks from the source into the sink", func(t *testing.T) {
+		consumer := service.NewConsumer(cfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Contains(t, sink.tables, "TEST_EVENTS")
+		require.Equal(t, []string{"TestEvent1", "TestEvent3"}, sink.names("eventtest"))
+
+		height, found, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, "3", height)
+	})
+
+	t.Run("successfully resumes from the sink checkpoint", func(t *testing.T) {
+		source.addBlock(t, 4, "TestEvent4")
+
+		consumer := service.NewConsumer(cfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"TestEvent1", "TestEvent3", "TestEvent4"}, sink.names("eventtest"))
+
+		height, _, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "4", height)
+	})
+
+	t.Run("stops once the schema of the sink has been swapped", func(t *testing.T) {
+		consumer := service.NewConsumer(cfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = &swappedSink{}
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+	})
+
+	t.Run("stops at the latest block if the last block to consume is not produced yet", func(t *testing.T) {
+		source := &memorySource{}
+		source.addBlock(t, 1, "TestEvent1")
+		source.addBlock(t, 2, "TestEvent2")
+
+		sink := &memorySink{}
+
+		toCfg := *cfg
+		toCfg.ToHeight = 10
+
+		consumer := service.NewConsumer(&toCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"TestEvent1", "TestEvent2"}, sink.names("eventtest"))
+	})
+
+	t.Run("successfully checkpoints the last block to consume even if it has no events", func(t *testing.T) {
+		source := &memorySource{}
+		source.addBlock(t, 1, "TestEvent1")
+		source.addBlock(t, 2)
+		source.addBlock(t, 3)
+		source.addBlock(t, 4, "TestEvent4")
+
+		sink := &memorySink{}
+
+		toCfg := *cfg
+		toCfg.ToHeight = 3
+
+		consumer := service.NewConsumer(&toCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"TestEvent1"}, sink.names("eventtest"))
+
+		height, _, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "3", height)
+	})
+
+	t.Run("successfully catches up with a checkpoint of a block without events", func(t *testing.T) {
+		source := &memorySource{}
+		source.addBlock(t, 1, "TestEvent1")
+		source.addBlock(t, 2, "TestEvent2")
+		source.addBlock(t, 3)
+
+		sink := &memorySink{}
+
+		// as the reindex command catches up with the schema checkpoint
+		for _, toHeight := range []uint64{2, 3, 3} {
+			toCfg := *cfg
+			toCfg.ToHeight = toHeight
+
+			consumer := service.NewConsumer(&toCfg, logger.NewLogger(cfg.LogLevel))
+			consumer.Source = source
+			consumer.Sink = sink
+
+			err := consumer.Run(context.Background())
+			require.NoError(t, err)
+
+			height, _, err := sink.GetCheckpoint(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("%v", toHeight), height)
+		}
+
+		require.Equal(t, []string{"TestEvent1", "TestEvent2"}, sink.names("eventtest"))
+	})
+
+	t.Run("waits for the last block to consume in follow mode", func(t *testing.T) {
+		source := &memorySource{}
+		source.addBlock(t, 1, "TestEvent1")
+
+		sink := &memorySink{}
+
+		toCfg := *cfg
+		toCfg.ToHeight = 3
+		toCfg.Follow = true
+
+		consumer := service.NewConsumer(&toCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		done := make(chan error, 1)
+		go func() {
+			done <- consumer.Run(context.Background())
+		}()
+
+		// blocks are produced once the consumer reached the latest block
+		time.Sleep(100 * time.Millisecond)
+		source.addBlock(t, 2)
+		source.addBlock(t, 3, "TestEvent3")
+		source.addBlock(t, 4, "TestEvent4")
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("consumer did not stop at the last block to consume")
+		}
+
+		require.Equal(t, []string{"TestEvent1", "TestEvent3"}, sink.names("eventtest"))
+	})
+
+	t.Run("successfully consumes blocks into files of the configured format", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "vent")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		fileCfg := *cfg
+		fileCfg.Sink = filesink.FormatJSONL
+		fileCfg.FileDir = dir
+
+		consumer := service.NewConsumer(&fileCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+
+		err = consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		byteValue, err := ioutil.ReadFile(filepath.Join(dir, cfg.DBSchema, "eventtest-1.jsonl"))
+		require.NoError(t, err)
+		require.Equal(t, 3, strings.Count(string(byteValue), "\n"))
+		require.Contains(t, string(byteValue), `"testname":"TestEvent4"`)
+	})
+}
+
+func TestRunUndecodedEvents(t *testing.T) {
+	cfg := config.DefaultFlags()
+	cfg.LogLevel = "none"
+
+	t.Run("skips logs of events sharing the signature of an event with another topics/data shape", func(t *testing.T) {
+		// an ERC-20 Transfer definition
+		transferJSON := strings.Replace(test.AnonymousJSONConfFile(t), `"anonymous": true`, `"anonymous": false`, 1)
+
+		cfgFile, cleanup := writeCfgFile(t, transferJSON)
+		defer cleanup()
+
+		transferCfg := *cfg
+		transferCfg.CfgFile = cfgFile
+
+		eventID := binary.LeftPadWord256(sha3.Sha3([]byte("Transfer(address,address,uint256)")))
+		addressTopic := binary.LeftPadWord256([]byte{0x01})
+
+		address, err := crypto.AddressFromHexString("4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51")
+		require.NoError(t, err)
+
+		// an ERC-721 Transfer log (with an indexed token id) and an ERC-20 one
+		source := &memorySource{}
+		source.addLogs(1, &exec.LogEvent{Address: address, Topics: []binary.Word256{eventID, addressTopic, addressTopic, binary.LeftPadWord256([]byte{0x2A})}})
+		source.addLogs(2, &exec.LogEvent{Address: address, Topics: []binary.Word256{eventID, addressTopic, addressTopic}, Data: binary.LeftPadWord256([]byte{0x2A}).Bytes()})
+
+		sink := &memorySink{}
+
+		consumer := service.NewConsumer(&transferCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err = consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		height, _, err := sink.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "2", height)
+
+		require.Equal(t, 2, len(sink.blocks))
+		require.Empty(t, sink.blocks[0].Tables["transfers"])
+		require.Equal(t, "42", sink.blocks[1].Tables["transfers"][0]["amount"])
+	})
+
+	t.Run("skips logs which can not be decoded", func(t *testing.T) {
+		cfgFile, cleanup := writeCfgFile(t, test.GoodJSONConfFile(t))
+		defer cleanup()
+
+		goodCfg := *cfg
+		goodCfg.CfgFile = cfgFile
+
+		// a string offset out of log data bounds
+		data := make([]byte, 3*32)
+		data[0] = 0xFF
+
+		source := &memorySource{}
+		source.addLogs(1, &exec.LogEvent{Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))}, Data: data})
+		source.addBlock(t, 2, "TestEvent2")
+
+		sink := &memorySink{}
+
+		consumer := service.NewConsumer(&goodCfg, logger.NewLogger(cfg.LogLevel))
+		consumer.Source = source
+		consumer.Sink = sink
+
+		err := consumer.Run(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"TestEvent2"}, sink.names("eventtest"))
+	})
+}
+
+// writeCfgFile writes an events config file, returns its name along with a function to remove it
+func writeCfgFile(t *testing.T, content string) (string, func()) {
+	cfgFile, err := ioutil.TempFile("", "sqlsol")
+	require.NoError(t, err)
+	cfgFile.Close()
+
+	require.NoError(t, ioutil.WriteFile(cfgFile.Name(), []byte(content), 0644))
+	return cfgFile.Name(), func() { os.Remove(cfgFile.Name()) }
+}
+
+// memorySource streams blocks held in memory (blocks can be added while streaming)
+type memorySource struct {
+	sync.Mutex
+	blocks []*exec.BlockExecution
+}
+
+// addBlock adds a block with a TEST_EVENTS log event for each given name
+func (s *memorySource) addBlock(t *testing.T, height uint64, names ...string) {
+	block := &exec.BlockExecution{Height: height}
+
+	for i, name := range names {
+		data, err := abi.Pack([]abi.Argument{
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMString{}},
+			{EVM: abi.EVMUint{M: 256}},
+		}, name, "Description of "+name, 42)
+		require.NoError(t, err)
+
+		txHash := []byte(name)
+		block.TxExecutions = append(block.TxExecutions, &exec.TxExecution{
+			TxHash: txHash,
+			Height: height,
+			Events: []*exec.Event{{
+				Header: &exec.Header{TxHash: txHash, EventType: exec.TypeLog, Height: height, Index: uint64(i)},
+				Log: &exec.LogEvent{
+					Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+					Data:   data,
+				},
+			}},
+		})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.blocks = append(s.blocks, block)
+}
+
+// addLogs adds a block with a transaction emitting the given logs
+func (s *memorySource) addLogs(height uint64, logs ...*exec.LogEvent) {
+	txHash := []byte(fmt.Sprintf("tx%d", height))
+	txe := &exec.TxExecution{TxHash: txHash, Height: height}
+
+	for i, log := range logs {
+		txe.Events = append(txe.Events, &exec.Event{
+			Header: &exec.Header{TxHash: txHash, EventType: exec.TypeLog, Height: height, Index: uint64(i)},
+			Log:    log,
+		})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.blocks = append(s.blocks, &exec.BlockExecution{Height: height, TxExecutions: []*exec.TxExecution{txe}})
+}
+
+func (s *memorySource) Events(ctx context.Context, blockRange *rpcevents.BlockRange, qry string) (service.EventsStream, error) {
+	matcher, err := query.New(qry)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []*rpcevents.GetEventsResponse
+
+	for _, block := range s.inRange(blockRange) {
+		resp := &rpcevents.GetEventsResponse{Height: block.Height}
+		for _, txe := range block.TxExecutions {
+			for _, ev := range txe.Events {
+				if matcher.Matches(ev.Tagged()) {
+					resp.Events = append(resp.Events, ev)
+				}
+			}
+		}
+		if len(resp.Events) > 0 {
+			responses = append(responses, resp)
+		}
+	}
+
+	return &memoryEventsStream{responses: responses}, nil
+}
+
+func (s *memorySource) Blocks(ctx context.Context, blockRange *rpcevents.BlockRange) (service.BlocksStream, error) {
+	return &memoryBlocksStream{blocks: s.inRange(blockRange)}, nil
+}
+
+func (s *memorySource) LatestHeight(ctx context.Context) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.blocks[len(s.blocks)-1].Height, nil
+}
+
+func (s *memorySource) inRange(blockRange *rpcevents.BlockRange) []*exec.BlockExecution {
+	latest, _ := s.LatestHeight(context.Background())
+	start, end, _ := blockRange.Bounds(latest)
+
+	s.Lock()
+	defer s.Unlock()
+
+	var blocks []*exec.BlockExecution
+	for _, block := range s.blocks {
+		if block.Height >= start && block.Height < end {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
+}
+
+type memoryEventsStream struct {
+	responses []*rpcevents.GetEventsResponse
+}
+
+func (s *memoryEventsStream) Recv() (*rpcevents.GetEventsResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+type memoryBlocksStream struct {
+	blocks []*exec.BlockExecution
+}
+
+func (s *memoryBlocksStream) Recv() (*exec.BlockExecution, error) {
+	if len(s.blocks) == 0 {
+		return nil, io.EOF
+	}
+	block := s.blocks[0]
+	s.blocks = s.blocks[1:]
+	return block, nil
+}
+
+// memorySink stores blocks in memory
+type memorySink struct {
+	tables  types.EventTables
+	blocks  []types.EventData
+	commits [][]string
+}
+
+func (s *memorySink) SynchronizeDB(ctx context.Context, eventTables types.EventTables) error {
+	s.tables = eventTables
+	return nil
+}
+
+func (s *memorySink) GetCheckpoint(ctx context.Context) (string, bool, error) {
+	if len(s.blocks) == 0 {
+		return "", false, nil
+	}
+	return s.blocks[len(s.blocks)-1].Block, true, nil
+}
+
+func (s *memorySink) SetBlocks(ctx context.Context, eventTables types.EventTables, blocks []types.EventData) error {
+	s.blocks = append(s.blocks, blocks...)
+
+	var heights []string
+	for _, block := range blocks {
+		heights = append(heights, block.Block)
+	}
+	s.commits = append(s.commits, heights)
+	return nil
+}
+
+// names returns the names of every event stored in the table
+func (s *memorySink) names(tableName string) []string {
+	var names []string
+	for _, block := range s.blocks {
+		for _, row := range block.Tables[tableName] {
+			names = append(names, row["testname"])
+		}
+	}
+	return names
+}
+
+// swappedSink fails to store blocks as its schema has been swapped
+type swappedSink struct {
+	memorySink
+}
+
+func (s *swappedSink) SetBlocks(ctx context.Context, eventTables types.EventTables, blocks []types.EventData) error {
+	return sqldb.ErrSchemaSwapped
+}
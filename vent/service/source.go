@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/hyperledger/burrow/rpc/rpcevents"
+	"github.com/hyperledger/burrow/rpc/rpcquery"
+	"google.golang.org/grpc"
+)
+
+// Source provides the blocks events are consumed from (a Burrow node by default),
+// it can be replaced i.e. to consume events from blocks held in memory
+type Source interface {
+	// Events streams the events matching the query of each block of the range with matching events
+	Events(ctx context.Context, blockRange *rpcevents.BlockRange, query string) (EventsStream, error)
+	// Blocks streams every block of the range, with its header, transactions and events
+	Blocks(ctx context.Context, blockRange *rpcevents.BlockRange) (BlocksStream, error)
+	// LatestHeight returns the height of the latest block of the chain
+	LatestHeight(ctx context.Context) (uint64, error)
+}
+
+// EventsStream receives the matching events of a block at a time
+type EventsStream interface {
+	Recv() (*rpcevents.GetEventsResponse, error)
+}
+
+// BlocksStream receives a block at a time
+type BlocksStream interface {
+	Recv() (*exec.BlockExecution, error)
+}
+
+// grpcSource gets blocks from the Burrow gRPC server
+type grpcSource struct {
+	cli      rpcevents.ExecutionEventsClient
+	queryCli rpcquery.QueryClient
+}
+
+// NewGRPCSource constructs a source getting blocks from the Burrow gRPC server of the given connection
+func NewGRPCSource(conn *grpc.ClientConn) Source {
+	return &grpcSource{
+		cli:      rpcevents.NewExecutionEventsClient(conn),
+		queryCli: rpcquery.NewQueryClient(conn),
+	}
+}
+
+// Events streams matching events from the events server
+func (s *grpcSource) Events(ctx context.Context, blockRange *rpcevents.BlockRange, query string) (EventsStream, error) {
+	return s.cli.GetEvents(ctx, &rpcevents.BlocksRequest{BlockRange: blockRange, Query: query})
+}
+
+// Blocks streams whole blocks from the events server
+func (s *grpcSource) Blocks(ctx context.Context, blockRange *rpcevents.BlockRange) (BlocksStream, error) {
+	return s.cli.GetBlocks(ctx, &rpcevents.BlocksRequest{BlockRange: blockRange})
+}
+
+// LatestHeight returns the height of the latest block from the node status
+func (s *grpcSource) LatestHeight(ctx context.Context) (uint64, error) {
+	status, err := s.queryCli.Status(ctx, &rpcquery.StatusParam{})
+	if err != nil {
+		return 0, err
+	}
+
+	return status.GetSyncInfo().GetLatestBlockHeight(), nil
+}
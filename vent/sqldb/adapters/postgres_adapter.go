@@ -17,6 +17,9 @@ var sqlDataTypes = map[types.SQLColumnType]string{
 	types.SQLColumnTypeText:      "TEXT",
 	types.SQLColumnTypeVarchar:   "VARCHAR",
 	types.SQLColumnTypeTimeStamp: "TIMESTAMP",
+	types.SQLColumnTypeBigInt:    "BIGINT",
+	types.SQLColumnTypeNumeric:   "NUMERIC",
+	types.SQLColumnTypeJSON:      "JSONB",
 }
 
 // PostgresAdapter implements DBAdapter for Postgres
@@ -173,6 +176,11 @@ func (adapter *PostgresAdapter) LastBlockIDQuery() string {
 
 // FindSchemaQuery returns a query that checks if the default schema exists
 func (adapter *PostgresAdapter) FindSchemaQuery() string {
+	return adapter.SchemaExistsQuery(adapter.Schema)
+}
+
+// SchemaExistsQuery returns a query that checks if a given schema exists
+func (adapter *PostgresAdapter) SchemaExistsQuery(schema string) string {
 	query := `
 		SELECT
 			EXISTS (
@@ -185,7 +193,13 @@ func (adapter *PostgresAdapter) FindSchemaQuery() string {
 			)
 	;`
 
-	return fmt.Sprintf(query, adapter.Schema)
+	return fmt.Sprintf(query, schema)
+}
+
+// SchemaIDQuery returns a query for the identifier of the default schema,
+// which is kept when the schema is renamed
+func (adapter *PostgresAdapter) SchemaIDQuery() string {
+	return fmt.Sprintf("SELECT oid::TEXT FROM pg_catalog.pg_namespace WHERE nspname = '%s';", adapter.Schema)
 }
 
 // CreateSchemaQuery returns a query that creates a PostgreSQL schema
@@ -198,6 +212,11 @@ func (adapter *PostgresAdapter) DropSchemaQuery() string {
 	return fmt.Sprintf("DROP SCHEMA %s CASCADE;", adapter.Schema)
 }
 
+// RenameSchemaQuery returns a query that renames a PostgreSQL schema
+func (adapter *PostgresAdapter) RenameSchemaQuery(schema string, newSchema string) string {
+	return fmt.Sprintf("ALTER SCHEMA %s RENAME TO %s;", schema, newSchema)
+}
+
 // FindTableQuery returns a query that checks if a table exists
 func (adapter *PostgresAdapter) FindTableQuery(tableName string) string {
 	query := `
@@ -240,6 +259,9 @@ func (adapter *PostgresAdapter) TableDefinitionQuery(tableName string) string {
 					WHEN c.data_type = 'text' THEN %v
 					WHEN c.udt_name = 'timestamp' THEN %v
 					WHEN c.udt_name = 'varchar' THEN %v
+					WHEN c.data_type = 'bigint' THEN %v
+					WHEN c.data_type = 'numeric' THEN %v
+					WHEN c.data_type = 'jsonb' THEN %v
 					ELSE 0
 				END
 			) ColumnSQLType,
@@ -249,7 +271,7 @@ func (adapter *PostgresAdapter) TableDefinitionQuery(tableName string) string {
 					ELSE false
 				END
 			) ColumnIsPK,
-			COALESCE(c.character_maximum_length,0) ColumnLength
+			COALESCE(c.character_maximum_length,c.numeric_precision,0) ColumnLength
 		FROM
 			information_schema.columns AS c
 		LEFT OUTER JOIN
@@ -264,6 +286,9 @@ func (adapter *PostgresAdapter) TableDefinitionQuery(tableName string) string {
 		types.SQLColumnTypeText,
 		types.SQLColumnTypeTimeStamp,
 		types.SQLColumnTypeVarchar,
+		types.SQLColumnTypeBigInt,
+		types.SQLColumnTypeNumeric,
+		types.SQLColumnTypeJSON,
 		adapter.Schema,
 		tableName,
 	)
@@ -272,7 +297,7 @@ func (adapter *PostgresAdapter) TableDefinitionQuery(tableName string) string {
 // AlterColumnQuery returns a query for adding a new column to a table
 func (adapter *PostgresAdapter) AlterColumnQuery(tableName string, columnName string, sqlColumnType types.SQLColumnType) string {
 	sqlType, _ := adapter.TypeMapping(sqlColumnType)
-	return fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s %s;", adapter.Schema, tableName, tableName, sqlType)
+	return fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s %s;", adapter.Schema, tableName, columnName, sqlType)
 }
 
 // SelectRowQuery returns a query for selecting row values
@@ -306,6 +331,29 @@ func (adapter *PostgresAdapter) InsertLogDetailQuery() string {
 	return fmt.Sprintf("INSERT INTO %s._bosmarmot_logdet (id, tblname, tblmap, registers) VALUES ($1, $2, $3, $4)", adapter.Schema)
 }
 
+// SelectCheckpointQuery returns a query for the last completely processed block in checkpoint table
+func (adapter *PostgresAdapter) SelectCheckpointQuery() string {
+	return fmt.Sprintf("SELECT height FROM %s._bosmarmot_checkpoint WHERE id = 1;", adapter.Schema)
+}
+
+// UpsertCheckpointQuery returns a query to insert or update the row in checkpoint table
+func (adapter *PostgresAdapter) UpsertCheckpointQuery() string {
+	query := `
+		INSERT INTO %s._bosmarmot_checkpoint (id, timestamp, height)
+		VALUES (1, CURRENT_TIMESTAMP, $1)
+		ON CONFLICT ON CONSTRAINT _bosmarmot_checkpoint_pkey
+		DO UPDATE SET timestamp = EXCLUDED.timestamp, height = EXCLUDED.height
+	;`
+
+	return fmt.Sprintf(query, adapter.Schema)
+}
+
+// LockCheckpointQuery returns a query for the last completely processed block in checkpoint table,
+// locking its row until the end of the transaction
+func (adapter *PostgresAdapter) LockCheckpointQuery() string {
+	return fmt.Sprintf("SELECT height FROM %s._bosmarmot_checkpoint WHERE id = 1 FOR UPDATE;", adapter.Schema)
+}
+
 // ErrorEquals verify if an error is of a given SQL type
 func (adapter *PostgresAdapter) ErrorEquals(err error, sqlErrorType types.SQLErrorType) bool {
 	if err, ok := err.(*pq.Error); ok {
@@ -0,0 +1,19 @@
+package adapters_test
+
+import (
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/sqldb/adapters"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlterColumnQuery(t *testing.T) {
+	t.Run("successfully builds a query adding the given column to the table", func(t *testing.T) {
+		adapter := adapters.NewPostgresAdapter("test_schema", logger.NewLogger("none"))
+
+		query := adapter.AlterColumnQuery("test_table", "test_column", types.SQLColumnTypeText)
+		require.Equal(t, "ALTER TABLE test_schema.test_table ADD COLUMN test_column TEXT;", query)
+	})
+}
@@ -14,8 +14,11 @@ type DBAdapter interface {
 	UpsertQuery(table types.SQLTable) types.UpsertQuery
 	LastBlockIDQuery() string
 	FindSchemaQuery() string
+	SchemaExistsQuery(schema string) string
+	SchemaIDQuery() string
 	CreateSchemaQuery() string
 	DropSchemaQuery() string
+	RenameSchemaQuery(schema string, newSchema string) string
 	FindTableQuery(tableName string) string
 	TableDefinitionQuery(tableName string) string
 	AlterColumnQuery(tableName string, columnName string, sqlColumnType types.SQLColumnType) string
@@ -23,5 +26,8 @@ type DBAdapter interface {
 	SelectLogQuery() string
 	InsertLogQuery() string
 	InsertLogDetailQuery() string
+	SelectCheckpointQuery() string
+	UpsertCheckpointQuery() string
+	LockCheckpointQuery() string
 	ErrorEquals(err error, sqlErrorType types.SQLErrorType) bool
 }
@@ -1,21 +1,27 @@
 package sqldb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/monax/bosmarmot/vent/logger"
 	"github.com/monax/bosmarmot/vent/sqldb/adapters"
 	"github.com/monax/bosmarmot/vent/types"
 )
 
+// ErrSchemaSwapped is returned when storing a block in a schema swapped with a shadow one
+var ErrSchemaSwapped = errors.New("Schema has been swapped")
+
 // SQLDB implements the access to a sql database
 type SQLDB struct {
 	DB        *sql.DB
 	DBAdapter DBAdapter
 	Schema    string
 	Log       *logger.Logger
+	schemaID  string
 }
 
 // NewSQLDB delegates work to a specific database adapter implementation,
@@ -57,8 +63,13 @@ func NewSQLDB(dbAdapter, dbURL, schema string, log *logger.Logger) (*SQLDB, erro
 		}
 	}
 
+	// the schema is identified to detect when it is swapped
+	if db.schemaID, err = db.getSchemaID(context.Background(), db.DB); err != nil {
+		return nil, err
+	}
+
 	// create _bosmarmot_log
-	if err = db.SynchronizeDB(db.getLogTableDef()); err != nil {
+	if err = db.SynchronizeDB(context.Background(), db.getLogTableDef()); err != nil {
 		return nil, err
 	}
 
@@ -83,13 +94,13 @@ func (db *SQLDB) Ping() error {
 }
 
 // GetLastBlockID returns last inserted blockId from log table
-func (db *SQLDB) GetLastBlockID() (string, error) {
+func (db *SQLDB) GetLastBlockID(ctx context.Context) (string, error) {
 	query := db.DBAdapter.LastBlockIDQuery()
 	id := ""
 
 	db.Log.Debug("msg", "MAX ID", "query", clean(query))
 
-	if err := db.DB.QueryRow(query).Scan(&id); err != nil {
+	if err := db.DB.QueryRowContext(ctx, query).Scan(&id); err != nil {
 		db.Log.Debug("msg", "Error selecting last block id", "err", err)
 		return "", err
 	}
@@ -97,6 +108,24 @@ func (db *SQLDB) GetLastBlockID() (string, error) {
 	return id, nil
 }
 
+// GetCheckpoint returns the last completely processed block from checkpoint table,
+// found is false if no block has been processed yet
+func (db *SQLDB) GetCheckpoint(ctx context.Context) (height string, found bool, err error) {
+	query := db.DBAdapter.SelectCheckpointQuery()
+
+	db.Log.Debug("msg", "CHECKPOINT", "query", clean(query))
+
+	if err := db.DB.QueryRowContext(ctx, query).Scan(&height); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		db.Log.Debug("msg", "Error selecting checkpoint", "err", err)
+		return "", false, err
+	}
+
+	return height, true, nil
+}
+
 // DestroySchema deletes the default schema
 func (db *SQLDB) DestroySchema() error {
 	db.Log.Info("msg", "Dropping schema")
@@ -120,20 +149,117 @@ func (db *SQLDB) DestroySchema() error {
 	return nil
 }
 
+// SchemaExists checks if a given schema exists
+func (db *SQLDB) SchemaExists(schema string) (bool, error) {
+	var found bool
+
+	query := db.DBAdapter.SchemaExistsQuery(safe(schema))
+
+	db.Log.Debug("msg", "FIND SCHEMA", "query", clean(query))
+	if err := db.DB.QueryRow(query).Scan(&found); err != nil {
+		db.Log.Debug("msg", "Error searching schema", "err", err)
+		return false, err
+	}
+
+	return found, nil
+}
+
+// SwapSchema atomically replaces the default schema with a shadow one, the default schema
+// is renamed to the backup schema, it is only done if the shadow checkpoint has reached
+// the default schema one (which is returned), the checkpoint row stays locked meanwhile
+// so blocks can not be committed in the default schema (schemas are not swapped
+// if the default schema has no checkpoint, as there would be no row to lock)
+func (db *SQLDB) SwapSchema(ctx context.Context, shadow *SQLDB, backupSchema string) (swapped bool, height string, err error) {
+	db.Log.Info("msg", "Swapping schema", "shadow", shadow.Schema, "backup", backupSchema)
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		db.Log.Debug("msg", "Error beginning transaction", "err", err)
+		return false, "", err
+	}
+	defer tx.Rollback()
+
+	query := db.DBAdapter.LockCheckpointQuery()
+
+	db.Log.Debug("msg", "LOCK CHECKPOINT", "query", clean(query))
+	if err = tx.QueryRowContext(ctx, query).Scan(&height); err != nil {
+		if err == sql.ErrNoRows {
+			return false, "", fmt.Errorf("error schema %s has no checkpoint to lock", db.Schema)
+		}
+		db.Log.Debug("msg", "Error locking checkpoint", "err", err)
+		return false, "", err
+	}
+
+	shadowHeight, _, err := shadow.GetCheckpoint(ctx)
+	if err != nil {
+		return false, "", err
+	}
+
+	behind, err := isBehind(shadowHeight, height)
+	if err != nil {
+		return false, "", err
+	}
+	if behind {
+		return false, height, nil
+	}
+
+	renames := []string{
+		db.DBAdapter.RenameSchemaQuery(safe(db.Schema), safe(backupSchema)),
+		db.DBAdapter.RenameSchemaQuery(safe(shadow.Schema), safe(db.Schema)),
+	}
+
+	for _, query := range renames {
+		db.Log.Info("msg", "Rename schema", "query", query)
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			db.Log.Debug("msg", "Error renaming schema", "err", err)
+			return false, "", err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		db.Log.Debug("msg", "Error on commit", "err", err)
+		return false, "", err
+	}
+
+	return true, height, nil
+}
+
+// isBehind checks if a block height comes before another one (empty heights come first)
+func isBehind(height, other string) (bool, error) {
+	if other == "" {
+		return false, nil
+	}
+	if height == "" {
+		return true, nil
+	}
+
+	h, err := strconv.ParseUint(height, 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	o, err := strconv.ParseUint(other, 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	return h < o, nil
+}
+
 // SynchronizeDB synchronize config structures with SQL database table structures
-func (db *SQLDB) SynchronizeDB(eventTables types.EventTables) error {
+func (db *SQLDB) SynchronizeDB(ctx context.Context, eventTables types.EventTables) error {
 	db.Log.Info("msg", "Synchronizing DB")
 
 	for _, table := range eventTables {
-		found, err := db.findTable(table.Name)
+		found, err := db.findTable(ctx, table.Name)
 		if err != nil {
 			return err
 		}
 
 		if found {
-			err = db.alterTable(table)
+			err = db.alterTable(ctx, table)
 		} else {
-			err = db.createTable(table)
+			err = db.createTable(ctx, table)
 		}
 		if err != nil {
 			return err
@@ -143,42 +269,131 @@ func (db *SQLDB) SynchronizeDB(eventTables types.EventTables) error {
 	return nil
 }
 
-// SetBlock inserts or updates multiple rows and stores log info in SQL tables
-func (db *SQLDB) SetBlock(eventTables types.EventTables, eventData types.EventData) error {
-	var pointers []interface{}
-	var value string
-	var safeTable string
-	var logStmt *sql.Stmt
+// SetBlock inserts or updates multiple rows and stores log info in SQL tables,
+// the block is checkpointed as completely processed in the same transaction
+// (which is rolled back if the context is cancelled before the commit)
+func (db *SQLDB) SetBlock(ctx context.Context, eventTables types.EventTables, eventData types.EventData) error {
+	return db.SetBlocks(ctx, eventTables, []types.EventData{eventData})
+}
+
+// SetBlocks inserts or updates the rows of several consecutive blocks in a single transaction,
+// log info is still stored for each block and the last one is checkpointed as completely processed
+func (db *SQLDB) SetBlocks(ctx context.Context, eventTables types.EventTables, blocks []types.EventData) error {
+	if len(blocks) == 0 {
+		return nil
+	}
 
 	// begin tx
-	tx, err := db.DB.Begin()
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		db.Log.Debug("msg", "Error beginning transaction", "err", err)
 		return err
 	}
 	defer tx.Rollback()
 
+	// update checkpoint
+	lastBlock := blocks[len(blocks)-1].Block
+	query := db.DBAdapter.UpsertCheckpointQuery()
+
+	db.Log.Debug("msg", "UPSERT CHECKPOINT", "query", clean(query), "value", lastBlock)
+	if _, err = tx.ExecContext(ctx, query, lastBlock); err != nil {
+		db.Log.Debug("msg", "Error upserting into _bosmarmot_checkpoint", "err", err)
+		return err
+	}
+
+	// blocks are not committed once the schema has been swapped with a shadow one
+	// (the checkpoint row is locked while swapping)
+	schemaID, err := db.getSchemaID(ctx, tx)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if schemaID != db.schemaID {
+		db.Log.Warn("msg", "Schema swapped", "value", db.Schema)
+		return ErrSchemaSwapped
+	}
+
+	// blocks without rows only move the checkpoint forward
+	safeTable := ""
+	for _, eventData := range blocks {
+		if len(eventData.Tables) == 0 {
+			continue
+		}
+		if safeTable, err = db.setBlockRows(ctx, tx, eventTables, eventData); err != nil {
+			break
+		}
+	}
+
+	//------------------------error handling----------------------
+	if err != nil {
+		// rollback error
+		if errRb := tx.Rollback(); errRb != nil {
+			db.Log.Debug("msg", "Error on rollback", "err", errRb)
+			return errRb
+		}
+
+		if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeGeneric) {
+			// table does not exists
+			if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeUndefinedTable) {
+				db.Log.Warn("msg", "Table not found", "value", safeTable)
+				if err = db.SynchronizeDB(ctx, eventTables); err != nil {
+					return err
+				}
+				return db.SetBlocks(ctx, eventTables, blocks)
+			}
+
+			// columns do not match
+			if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeUndefinedColumn) {
+				db.Log.Warn("msg", "Column not found", "value", safeTable)
+				if err = db.SynchronizeDB(ctx, eventTables); err != nil {
+					return err
+				}
+				return db.SetBlocks(ctx, eventTables, blocks)
+			}
+
+			db.Log.Debug("msg", "Error upserting row", "err", err)
+			return err
+		}
+
+		return err
+	}
+
+	db.Log.Debug("msg", "COMMIT")
+
+	if err := tx.Commit(); err != nil {
+		db.Log.Debug("msg", "Error on commit", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// setBlockRows stores log info and upserts the rows of a block in the given transaction,
+// it returns the last table written (to be reported on errors)
+func (db *SQLDB) setBlockRows(ctx context.Context, tx *sql.Tx, eventTables types.EventTables, eventData types.EventData) (string, error) {
+	var pointers []interface{}
+	var value string
+	var safeTable string
+
 	// insert into log tables
 	id := 0
 	length := len(eventTables)
 	query := db.DBAdapter.InsertLogQuery()
 
 	db.Log.Debug("msg", "INSERT LOG", "query", clean(query), "value", fmt.Sprintf("%d %s", length, eventData.Block))
-	err = tx.QueryRow(query, length, eventData.Block).Scan(&id)
+	err := tx.QueryRowContext(ctx, query, length, eventData.Block).Scan(&id)
 	if err != nil {
 		db.Log.Debug("msg", "Error inserting into _bosmarmot_log", "err", err)
-		return err
+		return safeTable, err
 	}
 
 	// prepare log detail statement
 	logQuery := db.DBAdapter.InsertLogDetailQuery()
-	logStmt, err = tx.Prepare(logQuery)
+	logStmt, err := tx.PrepareContext(ctx, logQuery)
 	if err != nil {
 		db.Log.Debug("msg", "Error preparing log stmt", "err", err)
-		return err
+		return safeTable, err
 	}
 
-loop:
 	// for each table in the block
 	for tblMap, table := range eventTables {
 		safeTable = safe(table.Name)
@@ -187,10 +402,10 @@ loop:
 		dataRows := eventData.Tables[table.Name]
 		length = len(dataRows)
 		db.Log.Debug("msg", "INSERT LOGDET", "query", logQuery, "value", fmt.Sprintf("%d %s %s %d", id, safeTable, tblMap, length))
-		_, err = logStmt.Exec(id, safeTable, tblMap, length)
+		_, err = logStmt.ExecContext(ctx, id, safeTable, tblMap, length)
 		if err != nil {
 			db.Log.Debug("msg", "Error inserting into logdet", "err", err)
-			return err
+			return safeTable, err
 		}
 
 		// get table upsert query
@@ -202,69 +417,25 @@ loop:
 			pointers, value, err = getUpsertParams(uQuery, row)
 			if err != nil {
 				db.Log.Debug("msg", "Error building parameters", "err", err, "value", fmt.Sprintf("%v", row))
-				return err
+				return safeTable, err
 			}
 
 			// upsert row data
 			db.Log.Debug("msg", "UPSERT", "query", clean(uQuery.Query), "value", value)
-			_, err = tx.Exec(uQuery.Query, pointers...)
+			_, err = tx.ExecContext(ctx, uQuery.Query, pointers...)
 			if err != nil {
 				db.Log.Debug("msg", "Error Upserting", "err", err)
-				// exits from all loops -> continue in close log stmt
-				break loop
+				return safeTable, err
 			}
 		}
 	}
 
 	// close log statement
-	if err == nil {
-		if err = logStmt.Close(); err != nil {
-			db.Log.Debug("msg", "Error closing log stmt", "err", err)
-		}
-	}
-
-	//------------------------error handling----------------------
-	if err != nil {
-		// rollback error
-		if errRb := tx.Rollback(); errRb != nil {
-			db.Log.Debug("msg", "Error on rollback", "err", errRb)
-			return errRb
-		}
-
-		if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeGeneric) {
-			// table does not exists
-			if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeUndefinedTable) {
-				db.Log.Warn("msg", "Table not found", "value", safeTable)
-				if err = db.SynchronizeDB(eventTables); err != nil {
-					return err
-				}
-				return db.SetBlock(eventTables, eventData)
-			}
-
-			// columns do not match
-			if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeUndefinedColumn) {
-				db.Log.Warn("msg", "Column not found", "value", safeTable)
-				if err = db.SynchronizeDB(eventTables); err != nil {
-					return err
-				}
-				return db.SetBlock(eventTables, eventData)
-			}
-
-			db.Log.Debug("msg", "Error upserting row", "err", err)
-			return err
-		}
-
-		return err
-	}
-
-	db.Log.Debug("msg", "COMMIT")
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Debug("msg", "Error on commit", "err", err)
-		return err
+	if err = logStmt.Close(); err != nil {
+		db.Log.Debug("msg", "Error closing log stmt", "err", err)
 	}
 
-	return nil
+	return safeTable, err
 }
 
 // GetBlock returns a table's structure and row data for given block id
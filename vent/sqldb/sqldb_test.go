@@ -3,9 +3,13 @@
 package sqldb_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/monax/bosmarmot/vent/config"
+	"github.com/monax/bosmarmot/vent/logger"
+	"github.com/monax/bosmarmot/vent/sqldb"
 	"github.com/monax/bosmarmot/vent/sqlsol"
 	"github.com/monax/bosmarmot/vent/test"
 	"github.com/monax/bosmarmot/vent/types"
@@ -25,11 +29,89 @@ func TestSynchronizeDB(t *testing.T) {
 		errp := db.Ping()
 		require.NoError(t, errp)
 
-		err := db.SynchronizeDB(tableStruct.GetTables())
+		err := db.SynchronizeDB(context.Background(), tableStruct.GetTables())
 		require.NoError(t, err)
 	})
 }
 
+func TestSwapSchema(t *testing.T) {
+	t.Run("successfully swaps schemas once the shadow schema has caught up", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		shadow, closeShadow := test.NewTestDB(t)
+		defer closeShadow()
+
+		backupSchema := db.Schema + "_old"
+
+		str, _ := getBlock()
+
+		err := db.SetBlock(context.Background(), str, types.EventData{Block: "5"})
+		require.NoError(t, err)
+
+		// the shadow schema is behind
+		err = shadow.SetBlock(context.Background(), str, types.EventData{Block: "3"})
+		require.NoError(t, err)
+
+		swapped, height, err := db.SwapSchema(context.Background(), shadow, backupSchema)
+		require.NoError(t, err)
+		require.Equal(t, false, swapped)
+		require.Equal(t, "5", height)
+
+		// the shadow schema has caught up
+		err = shadow.SetBlock(context.Background(), str, types.EventData{Block: "5"})
+		require.NoError(t, err)
+
+		swapped, _, err = db.SwapSchema(context.Background(), shadow, backupSchema)
+		require.NoError(t, err)
+		require.Equal(t, true, swapped)
+
+		found, err := db.SchemaExists(backupSchema)
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+
+		found, err = db.SchemaExists(shadow.Schema)
+		require.NoError(t, err)
+		require.Equal(t, false, found)
+
+		// blocks can not be stored anymore in the swapped schema
+		err = db.SetBlock(context.Background(), str, types.EventData{Block: "6"})
+		require.Equal(t, sqldb.ErrSchemaSwapped, err)
+
+		cfg := config.DefaultFlags()
+		backup, err := sqldb.NewSQLDB(cfg.DBAdapter, cfg.DBURL, backupSchema, logger.NewLogger("debug"))
+		require.NoError(t, err)
+
+		height, _, err = backup.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "5", height)
+
+		backup.DestroySchema()
+		backup.Close()
+	})
+
+	t.Run("returns an error if the schema has no checkpoint", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		shadow, closeShadow := test.NewTestDB(t)
+		defer closeShadow()
+
+		str, _ := getBlock()
+
+		err := shadow.SetBlock(context.Background(), str, types.EventData{Block: "3"})
+		require.NoError(t, err)
+
+		swapped, _, err := db.SwapSchema(context.Background(), shadow, db.Schema+"_old")
+		require.Error(t, err)
+		require.Equal(t, false, swapped)
+
+		found, err := db.SchemaExists(shadow.Schema)
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+	})
+}
+
 func TestSetBlock(t *testing.T) {
 	t.Run("successfully inserts a block", func(t *testing.T) {
 
@@ -41,10 +123,10 @@ func TestSetBlock(t *testing.T) {
 
 		str, dat := getBlock()
 
-		err := db.SetBlock(str, dat)
+		err := db.SetBlock(context.Background(), str, dat)
 		require.NoError(t, err)
 
-		id, erri := db.GetLastBlockID()
+		id, erri := db.GetLastBlockID(context.Background())
 		fmt.Println("id=", id)
 		require.NoError(t, erri)
 
@@ -53,6 +135,54 @@ func TestSetBlock(t *testing.T) {
 		require.NoError(t, erre)
 	})
 
+	t.Run("successfully checkpoints blocks in the same transaction", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		_, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, false, found)
+
+		str, dat := getBlock()
+
+		err = db.SetBlock(context.Background(), str, dat)
+		require.NoError(t, err)
+
+		height, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, dat.Block, height)
+
+		// blocks without rows are checkpointed but not logged
+		err = db.SetBlock(context.Background(), str, types.EventData{Block: "5"})
+		require.NoError(t, err)
+
+		height, _, err = db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "5", height)
+
+		id, err := db.GetLastBlockID(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, dat.Block, id)
+	})
+
+	t.Run("rolls back the block if the context is cancelled", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		str, dat := getBlock()
+
+		err := db.SetBlock(ctx, str, dat)
+		require.Error(t, err)
+
+		_, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, false, found)
+	})
+
 	t.Run("successfully creates a table", func(t *testing.T) {
 		db, closeDB := test.NewTestDB(t)
 		defer closeDB()
@@ -73,8 +203,64 @@ func TestSetBlock(t *testing.T) {
 		tables := make(map[string]types.SQLTable)
 		tables["FullDataTable"] = table1
 
-		err := db.SynchronizeDB(tables)
+		err := db.SynchronizeDB(context.Background(), tables)
+		require.NoError(t, err)
+	})
+}
+
+func TestSetBlocks(t *testing.T) {
+	t.Run("successfully stores several blocks in the same transaction", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		str, dat := getBlock()
+
+		next := types.EventData{
+			Block: "0123456789ABCDEF1",
+			Tables: map[string]types.EventDataTable{
+				"test_table3": {{"height": "0123456789ABCDEF1", "val": "7"}},
+			},
+		}
+
+		err := db.SetBlocks(context.Background(), str, []types.EventData{dat, next, {Block: "0123456789ABCDEF2"}})
+		require.NoError(t, err)
+
+		// the last block is checkpointed
+		height, found, err := db.GetCheckpoint(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, true, found)
+		require.Equal(t, "0123456789ABCDEF2", height)
+
+		// log info is stored for each block with rows
+		id, err := db.GetLastBlockID(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, next.Block, id)
+
+		eventData, err := db.GetBlock(dat.Block)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(eventData.Tables["test_table1"]))
+
+		eventData, err = db.GetBlock(next.Block)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(eventData.Tables["test_table3"]))
+		require.Equal(t, "7", eventData.Tables["test_table3"][0]["val"])
+	})
+
+	t.Run("rolls back every block if the context is cancelled", func(t *testing.T) {
+		db, closeDB := test.NewTestDB(t)
+		defer closeDB()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		str, dat := getBlock()
+
+		err := db.SetBlocks(ctx, str, []types.EventData{dat, {Block: "0123456789ABCDEF1"}})
+		require.Error(t, err)
+
+		_, found, err := db.GetCheckpoint(context.Background())
 		require.NoError(t, err)
+		require.Equal(t, false, found)
 	})
 }
 
@@ -1,6 +1,7 @@
 package sqldb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -45,14 +46,34 @@ func (db *SQLDB) createDefaultSchema() error {
 	return err
 }
 
+// rowQuerier is either a database or a transaction
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// getSchemaID returns the identifier of the default schema
+func (db *SQLDB) getSchemaID(ctx context.Context, q rowQuerier) (string, error) {
+	var id string
+
+	query := db.DBAdapter.SchemaIDQuery()
+
+	db.Log.Debug("msg", "SCHEMA ID", "query", clean(query))
+	if err := q.QueryRowContext(ctx, query).Scan(&id); err != nil {
+		db.Log.Debug("msg", "Error selecting schema id", "err", err)
+		return "", err
+	}
+
+	return id, nil
+}
+
 // findTable checks if a table exists in the default schema
-func (db *SQLDB) findTable(tableName string) (bool, error) {
+func (db *SQLDB) findTable(ctx context.Context, tableName string) (bool, error) {
 	found := false
 	safeTable := safe(tableName)
 	query := db.DBAdapter.FindTableQuery(safeTable)
 
 	db.Log.Debug("msg", "FIND TABLE", "query", clean(query), "value", safeTable)
-	err := db.DB.QueryRow(query).Scan(&found)
+	err := db.DB.QueryRowContext(ctx, query).Scan(&found)
 
 	if err == nil {
 		if !found {
@@ -141,16 +162,46 @@ func (db *SQLDB) getLogTableDef() types.EventTables {
 		Columns: detCol,
 	}
 
+	// single row table holding the last completely processed block
+	chkCol := make(map[string]types.SQLTableColumn)
+
+	chkCol["id"] = types.SQLTableColumn{
+		Name:    "id",
+		Type:    types.SQLColumnTypeInt,
+		Primary: true,
+		Order:   1,
+	}
+
+	chkCol["timestamp"] = types.SQLTableColumn{
+		Name:    "timestamp",
+		Type:    types.SQLColumnTypeTimeStamp,
+		Primary: false,
+		Order:   2,
+	}
+
+	chkCol["height"] = types.SQLTableColumn{
+		Name:    "height",
+		Type:    types.SQLColumnTypeVarchar,
+		Length:  100,
+		Primary: false,
+		Order:   3,
+	}
+
+	tables["checkpoint"] = types.SQLTable{
+		Name:    "_bosmarmot_checkpoint",
+		Columns: chkCol,
+	}
+
 	return tables
 }
 
 // getTableDef returns the structure of a given SQL table
-func (db *SQLDB) getTableDef(tableName string) (types.SQLTable, error) {
+func (db *SQLDB) getTableDef(ctx context.Context, tableName string) (types.SQLTable, error) {
 	var table types.SQLTable
 
 	safeTable := safe(tableName)
 
-	found, err := db.findTable(safeTable)
+	found, err := db.findTable(ctx, safeTable)
 	if err != nil {
 		return table, err
 	}
@@ -164,7 +215,7 @@ func (db *SQLDB) getTableDef(tableName string) (types.SQLTable, error) {
 	query := db.DBAdapter.TableDefinitionQuery(safeTable)
 
 	db.Log.Debug("msg", "QUERY STRUCTURE", "query", clean(query), "value", safeTable)
-	rows, err := db.DB.Query(query)
+	rows, err := db.DB.QueryContext(ctx, query)
 	if err != nil {
 		db.Log.Debug("msg", "Error querying table structure", "err", err)
 		return table, err
@@ -196,7 +247,7 @@ func (db *SQLDB) getTableDef(tableName string) (types.SQLTable, error) {
 		column.Primary = columnIsPK
 		column.Type = columnSQLType
 
-		if column.Type == types.SQLColumnTypeVarchar {
+		if column.Type == types.SQLColumnTypeVarchar || column.Type == types.SQLColumnTypeNumeric {
 			column.Length = columnLength
 		} else {
 			column.Length = 0
@@ -215,13 +266,13 @@ func (db *SQLDB) getTableDef(tableName string) (types.SQLTable, error) {
 }
 
 // alterTable alters the structure of a SQL table
-func (db *SQLDB) alterTable(newTable types.SQLTable) error {
+func (db *SQLDB) alterTable(ctx context.Context, newTable types.SQLTable) error {
 	db.Log.Info("msg", "Altering table", "value", newTable.Name)
 
 	safeTable := safe(newTable.Name)
 
 	// current table structure
-	currentTable, err := db.getTableDef(safeTable)
+	currentTable, err := db.getTableDef(ctx, safeTable)
 	if err != nil {
 		return err
 	}
@@ -244,7 +295,7 @@ func (db *SQLDB) alterTable(newTable types.SQLTable) error {
 			query := db.DBAdapter.AlterColumnQuery(safeTable, safeCol, newColumn.Type)
 
 			db.Log.Debug("msg", "ALTER TABLE", "query", clean(query))
-			_, err = db.DB.Exec(query)
+			_, err = db.DB.ExecContext(ctx, query)
 			if err != nil {
 				if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeDuplicatedColumn) {
 					db.Log.Warn("msg", "Duplicate column", "value", safeCol)
@@ -280,7 +331,7 @@ func (db *SQLDB) getSelectQuery(table types.SQLTable, height string) (string, er
 }
 
 // createTable creates a new table in the default schema
-func (db *SQLDB) createTable(table types.SQLTable) error {
+func (db *SQLDB) createTable(ctx context.Context, table types.SQLTable) error {
 	db.Log.Info("msg", "Creating Table", "value", table.Name)
 
 	safeTable := safe(table.Name)
@@ -299,7 +350,7 @@ func (db *SQLDB) createTable(table types.SQLTable) error {
 
 	// create table
 	db.Log.Debug("msg", "CREATE TABLE", "query", clean(query))
-	_, err := db.DB.Exec(query)
+	_, err := db.DB.ExecContext(ctx, query)
 	if err != nil {
 		if db.DBAdapter.ErrorEquals(err, types.SQLErrorTypeDuplicatedColumn) {
 			db.Log.Warn("msg", "Duplicate table", "value", safeTable)
@@ -348,7 +399,7 @@ func (db *SQLDB) getBlockTables(block string) (types.EventTables, error) {
 			return tables, err
 		}
 
-		table, err = db.getTableDef(tblName)
+		table, err = db.getTableDef(context.Background(), tblName)
 		if err != nil {
 			return tables, err
 		}
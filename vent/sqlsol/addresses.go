@@ -0,0 +1,84 @@
+package sqlsol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hyperledger/burrow/crypto"
+)
+
+// deployJobPrefix identifies addresses to be read from a burrow deploy output file,
+// following the same convention used to reference job results in deploy jobs
+const deployJobPrefix = "$"
+
+// getAddresses parses a list of contract addresses,
+// job references (i.e. $deployContract) are resolved using the given deploy output file
+func getAddresses(addresses []string, deployFile string, deployOutputs map[string]map[string]interface{}) ([]crypto.Address, error) {
+	result := make([]crypto.Address, 0, len(addresses))
+
+	for _, address := range addresses {
+		if strings.HasPrefix(address, deployJobPrefix) {
+			job := strings.TrimPrefix(address, deployJobPrefix)
+
+			jobResults, err := readDeployOutput(deployFile, deployOutputs)
+			if err != nil {
+				return nil, err
+			}
+
+			jobResult, ok := jobResults[job]
+			if !ok {
+				return nil, fmt.Errorf("getAddresses: job %s not found in deploy output file %s", job, deployFile)
+			}
+
+			if address, ok = jobResult.(string); !ok {
+				return nil, fmt.Errorf("getAddresses: job %s result is not an address in deploy output file %s", job, deployFile)
+			}
+		}
+
+		addr, err := crypto.AddressFromHexString(address)
+		if err != nil {
+			return nil, fmt.Errorf("getAddresses: invalid contract address %s: %v", address, err)
+		}
+
+		result = append(result, addr)
+	}
+
+	return result, nil
+}
+
+// readDeployOutput reads job results from a burrow deploy output file,
+// files already read are taken from the given cache
+func readDeployOutput(deployFile string, deployOutputs map[string]map[string]interface{}) (map[string]interface{}, error) {
+	if jobResults, ok := deployOutputs[deployFile]; ok {
+		return jobResults, nil
+	}
+
+	if deployFile == "" {
+		return nil, fmt.Errorf("readDeployOutput: a deploy output file is needed to read job results")
+	}
+
+	byteValue, err := ioutil.ReadFile(deployFile)
+	if err != nil {
+		return nil, fmt.Errorf("readDeployOutput: can not read deploy output file %s: %v", deployFile, err)
+	}
+
+	jobResults := make(map[string]interface{})
+	if err := json.Unmarshal(byteValue, &jobResults); err != nil {
+		return nil, fmt.Errorf("readDeployOutput: can not parse deploy output file %s: %v", deployFile, err)
+	}
+
+	deployOutputs[deployFile] = jobResults
+	return jobResults, nil
+}
+
+// containsAddress checks if an address is in a list of addresses
+func containsAddress(addresses []crypto.Address, address crypto.Address) bool {
+	for _, addr := range addresses {
+		if addr == address {
+			return true
+		}
+	}
+	return false
+}
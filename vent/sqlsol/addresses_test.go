@@ -0,0 +1,100 @@
+package sqlsol_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddresses(t *testing.T) {
+	addressesJSON := test.AddressesJSONConfFile(t)
+
+	byteValue := []byte(addressesJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	address, err := crypto.AddressFromHexString("5B3D514AE393495334CDAF1DE01D52E5EA6A5D2F")
+	require.NoError(t, err)
+
+	event := &exec.Event{
+		Header: &exec.Header{EventType: exec.TypeLog},
+		Log: &exec.LogEvent{
+			Address: address,
+			Topics:  []binary.Word256{{}},
+		},
+	}
+
+	t.Run("successfully maps the same event to different tables", func(t *testing.T) {
+		tableName, err := tableStruct.GetTableName("UpdateUserAccount:useraccounts")
+		require.NoError(t, err)
+		require.Equal(t, "useraccounts", tableName)
+
+		tableName, err = tableStruct.GetTableName("UpdateUserAccount:otheraccounts")
+		require.NoError(t, err)
+		require.Equal(t, "otheraccounts", tableName)
+	})
+
+	t.Run("successfully matches an event emitted by one of the event contract addresses", func(t *testing.T) {
+		eventSpec, err := tableStruct.GetEventSpec("UpdateUserAccount:otheraccounts")
+		require.NoError(t, err)
+		require.Equal(t, true, eventSpec.Matches(event))
+	})
+
+	t.Run("does not match an event emitted by another contract address", func(t *testing.T) {
+		eventSpec, err := tableStruct.GetEventSpec("UpdateUserAccount:useraccounts")
+		require.NoError(t, err)
+		require.Equal(t, false, eventSpec.Matches(event))
+	})
+
+	t.Run("successfully adds the contract address as a column", func(t *testing.T) {
+		col, err := tableStruct.GetColumn("UpdateUserAccount:useraccounts", "contractAddress")
+		require.NoError(t, err)
+		require.Equal(t, "contractaddress", col.Name)
+	})
+
+	t.Run("returns an error if job addresses are given without a deploy file", func(t *testing.T) {
+		goodJSON := test.DeployFileJSONConfFile(t, "")
+
+		_, err := sqlsol.NewParser([]byte(goodJSON))
+		require.Error(t, err)
+	})
+}
+
+func TestDeployFile(t *testing.T) {
+	deployFile, err := ioutil.TempFile("", "deploy.output.json")
+	require.NoError(t, err)
+	defer os.Remove(deployFile.Name())
+
+	_, err = deployFile.WriteString(`{"deployEventsTest": "4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51", "addEvent": ""}`)
+	require.NoError(t, err)
+	require.NoError(t, deployFile.Close())
+
+	t.Run("successfully reads contract addresses from a deploy output file", func(t *testing.T) {
+		deployFileJSON := test.DeployFileJSONConfFile(t, deployFile.Name())
+
+		tableStruct, err := sqlsol.NewParser([]byte(deployFileJSON))
+		require.NoError(t, err)
+
+		eventSpec, err := tableStruct.GetEventSpec("UpdateTestEvents")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(eventSpec.Addresses))
+		require.Equal(t, "4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51", eventSpec.Addresses[0].String())
+
+		qry := tableStruct.GetEventsQuery()
+		require.Equal(t, "Address = '4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51'", qry.String())
+	})
+
+	t.Run("returns an error if the deploy output file does not exist", func(t *testing.T) {
+		deployFileJSON := test.DeployFileJSONConfFile(t, deployFile.Name()+".missing")
+
+		_, err := sqlsol.NewParser([]byte(deployFileJSON))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+package sqlsol
+
+import (
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// matchesShape checks if a log has the topics and data expected for the event inputs
+// (used to match anonymous events, which have no signature topic):
+// a topic for each indexed input holding a valid value for its type
+// and data with the size of non indexed inputs
+func (eventSpec EventSpec) matchesShape(log *exec.LogEvent) bool {
+	topic := 0
+	if !eventSpec.Event.Anonymous {
+		topic++
+	}
+
+	headSize := 0
+	dynamic := false
+
+	for _, input := range eventSpec.Event.Inputs {
+		evType, err := input.ParseType()
+		if err != nil {
+			return false
+		}
+
+		if input.Indexed {
+			if topic >= len(log.Topics) {
+				return false
+			}
+			// other indexed values are stored as hashes
+			if evType.IsValueType() && !isValidWord(evType, log.Topics[topic]) {
+				return false
+			}
+			topic++
+			continue
+		}
+
+		headSize += evType.HeadSize()
+		dynamic = dynamic || evType.IsDynamic()
+	}
+
+	if topic != len(log.Topics) {
+		return false
+	}
+
+	// dynamic values are padded to words after the head
+	if dynamic {
+		return len(log.Data) >= headSize && len(log.Data)%32 == 0
+	}
+
+	return len(log.Data) == headSize
+}
+
+// isValidWord checks if an ABI encoded word holds a valid value of the given type,
+// i.e. padding bytes are zero (or sign extension bytes for negative integers)
+func isValidWord(evType types.EventInputType, word binary.Word256) bool {
+	bytes := word.Bytes()
+
+	switch evType.Base {
+	case types.EventInputTypeAddress:
+		return isPadding(bytes[:12], 0)
+	case types.EventInputTypeBool:
+		return isPadding(bytes[:31], 0) && bytes[31] <= 1
+	case types.EventInputTypeUInt:
+		return isPadding(bytes[:32-evType.Size/8], 0)
+	case types.EventInputTypeInt:
+		padding := bytes[:32-evType.Size/8]
+		if bytes[32-evType.Size/8]&0x80 != 0 {
+			return isPadding(padding, 0xFF)
+		}
+		return isPadding(padding, 0)
+	case types.EventInputTypeBytes:
+		return isPadding(bytes[evType.Size:], 0)
+	default:
+		return true
+	}
+}
+
+// isPadding checks if all bytes have the padding value
+func isPadding(bytes []byte, value byte) bool {
+	for _, b := range bytes {
+		if b != value {
+			return false
+		}
+	}
+	return true
+}
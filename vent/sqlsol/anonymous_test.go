@@ -0,0 +1,69 @@
+package sqlsol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousEvents(t *testing.T) {
+	anonymousJSON := test.AnonymousJSONConfFile(t)
+
+	byteValue := []byte(anonymousJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	address, err := crypto.AddressFromHexString("4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51")
+	require.NoError(t, err)
+
+	otherAddress, err := crypto.AddressFromHexString("1DE01D52E5EA6A5D2F5B3D514AE393495334CDAF")
+	require.NoError(t, err)
+
+	addressTopic := binary.LeftPadWord256(otherAddress.Bytes())
+
+	t.Run("successfully matches anonymous events by contract address and topics/data shape", func(t *testing.T) {
+		log := &exec.LogEvent{
+			Address: address,
+			Topics:  []binary.Word256{addressTopic, addressTopic},
+			Data:    make([]byte, 32),
+		}
+
+		require.Equal(t, []string{"Transfer"}, tableStruct.GetEventNames(log))
+	})
+
+	t.Run("does not match anonymous events emitted by other contracts", func(t *testing.T) {
+		log := &exec.LogEvent{
+			Address: otherAddress,
+			Topics:  []binary.Word256{addressTopic, addressTopic},
+			Data:    make([]byte, 32),
+		}
+
+		require.Empty(t, tableStruct.GetEventNames(log))
+	})
+
+	t.Run("does not match logs with other topics/data shape", func(t *testing.T) {
+		logs := []*exec.LogEvent{
+			{Address: address, Topics: []binary.Word256{addressTopic}, Data: make([]byte, 32)},
+			{Address: address, Topics: []binary.Word256{addressTopic, addressTopic, addressTopic}, Data: make([]byte, 32)},
+			{Address: address, Topics: []binary.Word256{addressTopic, binary.RightPadWord256([]byte("not an address"))}, Data: make([]byte, 32)},
+			{Address: address, Topics: []binary.Word256{addressTopic, addressTopic}, Data: make([]byte, 64)},
+		}
+
+		for _, log := range logs {
+			require.Empty(t, tableStruct.GetEventNames(log))
+		}
+	})
+
+	t.Run("returns an error if an anonymous event is not scoped to contract addresses", func(t *testing.T) {
+		badJSON := strings.Replace(anonymousJSON, `"Addresses" : ["4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51"],`, "", 1)
+
+		_, err := sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+	})
+}
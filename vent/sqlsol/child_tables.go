@@ -0,0 +1,117 @@
+package sqlsol
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// OrdinalKey is the event data key of the ordinal of each array element stored in a child table
+const OrdinalKey = "_ordinal"
+
+// getChildTableKey returns the key of the child table of an array input
+func getChildTableKey(eventName, inputName string) string {
+	return fmt.Sprintf("%s.%s", eventName, inputName)
+}
+
+// getChildTable builds the table structure used to store each element of an array input,
+// rows are keyed by the parent table primary key and the element ordinal
+func getChildTable(parent types.SQLTable, eventInput types.EventInput, col types.EventColumn, globalColumns map[string]types.SQLTableColumn) (types.SQLTable, error) {
+	evType, err := eventInput.ParseType()
+	if err != nil || !evType.IsArray() {
+		return types.SQLTable{}, fmt.Errorf("getChildTable: input %s is not an array, only arrays can be stored in child tables", eventInput.Name)
+	}
+
+	if eventInput.Indexed {
+		return types.SQLTable{}, fmt.Errorf("getChildTable: input %s is indexed, only its hash is logged so it can not be stored in a child table", eventInput.Name)
+	}
+
+	columns := make(map[string]types.SQLTableColumn)
+	order := 0
+
+	for k, v := range globalColumns {
+		columns[k] = v
+		if v.Order > order {
+			order = v.Order
+		}
+	}
+
+	// parent primary key columns (sorted by column order)
+	var primaryKeys []string
+	for k, v := range parent.Columns {
+		if v.Primary {
+			primaryKeys = append(primaryKeys, k)
+		}
+	}
+
+	if len(primaryKeys) == 0 {
+		return types.SQLTable{}, fmt.Errorf("getChildTable: table %s has no primary key to relate child table rows of input %s", parent.Name, eventInput.Name)
+	}
+
+	sort.Slice(primaryKeys, func(i, j int) bool {
+		return parent.Columns[primaryKeys[i]].Order < parent.Columns[primaryKeys[j]].Order
+	})
+
+	for _, k := range primaryKeys {
+		order++
+		column := parent.Columns[k]
+		column.Order = order
+		columns[k] = column
+	}
+
+	order++
+	columns[OrdinalKey] = types.SQLTableColumn{
+		Name:    "ordinal",
+		Type:    types.SQLColumnTypeInt,
+		Primary: true,
+		Order:   order,
+	}
+
+	// array elements are mapped as an input of the array element type
+	elemInput := eventInput
+	elemInput.Type = eventInput.Type[:strings.LastIndex(eventInput.Type, "[")]
+
+	sqlType, sqlTypeLength, err := getSQLType(elemInput)
+	if err != nil {
+		return types.SQLTable{}, err
+	}
+
+	order++
+	columns[eventInput.Name] = types.SQLTableColumn{
+		Name:    col.Name,
+		Type:    sqlType,
+		Length:  sqlTypeLength,
+		Primary: false,
+		Order:   order,
+	}
+
+	return types.SQLTable{
+		Name:    strings.ToLower(fmt.Sprintf("%s_%s", parent.Name, col.Name)),
+		Columns: columns,
+	}, nil
+}
+
+// getArrayElements splits an array value (decoded as JSON) into its elements
+func getArrayElements(value string) ([]string, error) {
+	var rawElements []json.RawMessage
+
+	if err := json.Unmarshal([]byte(value), &rawElements); err != nil {
+		return nil, fmt.Errorf("getArrayElements: can not decode array %s: %v", value, err)
+	}
+
+	elements := make([]string, len(rawElements))
+
+	for i, rawElement := range rawElements {
+		var element string
+		if err := json.Unmarshal(rawElement, &element); err != nil {
+			// arrays and tuples are kept as JSON
+			element = string(rawElement)
+		}
+		elements[i] = element
+	}
+
+	return elements, nil
+}
@@ -0,0 +1,105 @@
+package sqlsol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildTables(t *testing.T) {
+	childTablesJSON := test.ChildTablesJSONConfFile(t)
+
+	byteValue := []byte(childTablesJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	t.Run("successfully builds child tables for expanded array inputs", func(t *testing.T) {
+		childTables := tableStruct.GetChildTables("UpdateGroup")
+		require.Equal(t, 2, len(childTables))
+
+		tableName, err := tableStruct.GetTableName(childTables["members"])
+		require.NoError(t, err)
+		require.Equal(t, "groups_member", tableName)
+
+		col, err := tableStruct.GetColumn(childTables["members"], "groupId")
+		require.NoError(t, err)
+		require.Equal(t, true, col.Primary)
+		require.Equal(t, "groupid", col.Name)
+
+		col, err = tableStruct.GetColumn(childTables["members"], sqlsol.OrdinalKey)
+		require.NoError(t, err)
+		require.Equal(t, true, col.Primary)
+		require.Equal(t, types.SQLColumnTypeInt, col.Type)
+
+		col, err = tableStruct.GetColumn(childTables["members"], "members")
+		require.NoError(t, err)
+		require.Equal(t, false, col.Primary)
+		require.Equal(t, "member", col.Name)
+		require.Equal(t, types.SQLColumnTypeVarchar, col.Type)
+
+		col, err = tableStruct.GetColumn(childTables["shares"], "shares")
+		require.NoError(t, err)
+		require.Equal(t, types.SQLColumnTypeNumeric, col.Type)
+
+		col, err = tableStruct.GetColumn(childTables["members"], "height")
+		require.NoError(t, err)
+		require.Equal(t, "height", col.Name)
+	})
+
+	t.Run("does not store expanded array inputs in the event table", func(t *testing.T) {
+		_, err := tableStruct.GetColumn("UpdateGroup", "members")
+		require.Error(t, err)
+
+		col, err := tableStruct.GetColumn("UpdateGroup", "roles")
+		require.NoError(t, err)
+		require.Equal(t, types.SQLColumnTypeJSON, col.Type)
+	})
+
+	t.Run("successfully maps event data to event and child table rows", func(t *testing.T) {
+		eventData := map[string]string{
+			"height":  "10",
+			"groupId": "AB",
+			"members": `["4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51","1DE01D52E5EA6A5D2F5B3D514AE393495334CDAF"]`,
+			"shares":  `["1","2"]`,
+			"roles":   `["3"]`,
+		}
+
+		eventRows, err := tableStruct.GetEventRows("UpdateGroup", eventData)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(eventRows))
+
+		require.Equal(t, 1, len(eventRows["groups"]))
+		require.Equal(t, "AB", eventRows["groups"][0]["groupid"])
+		require.Equal(t, `["3"]`, eventRows["groups"][0]["roles"])
+		require.Equal(t, "10", eventRows["groups"][0]["height"])
+
+		require.Equal(t, 2, len(eventRows["groups_member"]))
+		for i, row := range eventRows["groups_member"] {
+			require.Equal(t, "AB", row["groupid"])
+			require.Equal(t, "10", row["height"])
+			require.Equal(t, []string{"0", "1"}[i], row["ordinal"])
+		}
+		require.Equal(t, "1DE01D52E5EA6A5D2F5B3D514AE393495334CDAF", eventRows["groups_member"][1]["member"])
+
+		require.Equal(t, 2, len(eventRows["groups_share"]))
+		require.Equal(t, "2", eventRows["groups_share"][1]["share"])
+	})
+
+	t.Run("returns an error if an expanded input is not an array", func(t *testing.T) {
+		badJSON := strings.Replace(childTablesJSON, `"type": "address[]"`, `"type": "address"`, 1)
+
+		_, err := sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error if the event table has no primary key", func(t *testing.T) {
+		badJSON := strings.Replace(childTablesJSON, `"name" : "groupid", "primary" : true`, `"name" : "groupid", "primary" : false`, 1)
+
+		_, err := sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,277 @@
+package sqlsol
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/burrow/execution/evm/sha3"
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// defaultSeparator is used by the concat transform if no separator is given
+const defaultSeparator = "-"
+
+// DerivedColumn contains the specification of a column whose value
+// is computed by a transform from event inputs (or global event data)
+type DerivedColumn struct {
+	Key       string
+	Transform string
+	Inputs    []string
+	Decimals  int
+	Labels    []string
+	Separator string
+}
+
+// getDerivedColumns returns the derived columns of an event definition
+// (sorted by column key) with their SQL table columns
+func getDerivedColumns(eventDef types.EventDefinition) ([]DerivedColumn, map[string]types.SQLTableColumn, error) {
+	var derivedColumns []DerivedColumn
+	columns := make(map[string]types.SQLTableColumn)
+
+	eventInputs := make(map[string]types.EventInput)
+	for _, eventInput := range eventDef.Event.Inputs {
+		eventInputs[eventInput.Name] = eventInput
+	}
+
+	globalColumns := getGlobalColumns()
+
+	keys := make([]string, 0, len(eventDef.Columns))
+	for key, col := range eventDef.Columns {
+		if col.Transform != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		col := eventDef.Columns[key]
+
+		derived := DerivedColumn{
+			Key:       key,
+			Transform: col.Transform,
+			Inputs:    col.Inputs,
+			Decimals:  col.Decimals,
+			Labels:    col.Labels,
+			Separator: col.Separator,
+		}
+
+		if _, ok := globalColumns[key]; ok {
+			return nil, nil, fmt.Errorf("getDerivedColumns: column %s is a global column", key)
+		}
+
+		if len(derived.Inputs) == 0 {
+			derived.Inputs = []string{key}
+		}
+
+		if derived.Transform == types.ColumnTransformEnum && len(derived.Labels) == 0 {
+			return nil, nil, fmt.Errorf("getDerivedColumns: column %s enum transform needs labels", key)
+		}
+
+		if derived.Transform == types.ColumnTransformConcat && derived.Separator == "" {
+			derived.Separator = defaultSeparator
+		}
+
+		if derived.Transform != types.ColumnTransformConcat && len(derived.Inputs) != 1 {
+			return nil, nil, fmt.Errorf("getDerivedColumns: column %s transform %s takes a single input", key, derived.Transform)
+		}
+
+		for _, input := range derived.Inputs {
+			eventInput, ok := eventInputs[input]
+			if !ok {
+				// only concatenated keys can use global event data (height, index...)
+				if _, ok := globalColumns[input]; ok && derived.Transform == types.ColumnTransformConcat {
+					continue
+				}
+				return nil, nil, fmt.Errorf("getDerivedColumns: column %s input %s not found in event %s", key, input, eventDef.Event.Name)
+			}
+
+			if err := isValidTransformInput(derived.Transform, eventInput); err != nil {
+				return nil, nil, fmt.Errorf("getDerivedColumns: column %s: %v", key, err)
+			}
+		}
+
+		sqlType, sqlTypeLength := getDerivedSQLType(derived.Transform)
+
+		derivedColumns = append(derivedColumns, derived)
+		columns[key] = types.SQLTableColumn{
+			Name:    col.Name,
+			Type:    sqlType,
+			Length:  sqlTypeLength,
+			Primary: col.Primary,
+		}
+	}
+
+	return derivedColumns, columns, nil
+}
+
+// isValidTransformInput checks if an event input can be transformed by a given transform
+func isValidTransformInput(transform string, eventInput types.EventInput) error {
+	evType, err := eventInput.ParseType()
+	if err != nil {
+		return err
+	}
+
+	// only the hash of indexed dynamic values is logged
+	if eventInput.Indexed && !evType.IsValueType() || evType.IsArray() || evType.IsTuple() {
+		return fmt.Errorf("input %s of type %s can not be transformed", eventInput.Name, eventInput.Type)
+	}
+
+	valid := true
+
+	switch transform {
+	case types.ColumnTransformBytesToString:
+		valid = evType.Base == types.EventInputTypeBytes
+	case types.ColumnTransformDecimals, types.ColumnTransformEnum:
+		valid = evType.Base == types.EventInputTypeInt || evType.Base == types.EventInputTypeUInt
+	case types.ColumnTransformHex:
+		valid = evType.Base == types.EventInputTypeAddress || evType.Base == types.EventInputTypeBytes
+	case types.ColumnTransformChecksum:
+		valid = evType.Base == types.EventInputTypeAddress
+	}
+
+	if !valid {
+		return fmt.Errorf("input %s of type %s can not be transformed by %s", eventInput.Name, eventInput.Type, transform)
+	}
+
+	return nil
+}
+
+// getDerivedSQLType maps transforms with corresponding SQL column types
+func getDerivedSQLType(transform string) (types.SQLColumnType, int) {
+	switch transform {
+	case types.ColumnTransformDecimals:
+		// unconstrained numeric keeps every decimal digit
+		return types.SQLColumnTypeNumeric, 0
+	case types.ColumnTransformChecksum:
+		return types.SQLColumnTypeVarchar, 42
+	default:
+		return types.SQLColumnTypeText, 0
+	}
+}
+
+// addDerivedData returns event data along with values of derived columns
+// (all of them computed from decoded event data)
+func addDerivedData(derivedColumns []DerivedColumn, eventData map[string]string) (map[string]string, error) {
+	if len(derivedColumns) == 0 {
+		return eventData, nil
+	}
+
+	data := make(map[string]string, len(eventData)+len(derivedColumns))
+	for k, v := range eventData {
+		data[k] = v
+	}
+
+	for _, derived := range derivedColumns {
+		value, err := derived.derive(eventData)
+		if err != nil {
+			return nil, fmt.Errorf("addDerivedData: can not derive column %s: %v", derived.Key, err)
+		}
+		data[derived.Key] = value
+	}
+
+	return data, nil
+}
+
+// derive computes the value of a derived column from event data
+func (derived DerivedColumn) derive(eventData map[string]string) (string, error) {
+	values := make([]string, len(derived.Inputs))
+	for i, input := range derived.Inputs {
+		values[i] = eventData[input]
+	}
+
+	switch derived.Transform {
+	case types.ColumnTransformBytesToString:
+		return bytesToString(values[0])
+	case types.ColumnTransformDecimals:
+		return scaleDecimals(values[0], derived.Decimals)
+	case types.ColumnTransformEnum:
+		return enumLabel(values[0], derived.Labels)
+	case types.ColumnTransformHex:
+		return "0x" + strings.ToLower(values[0]), nil
+	case types.ColumnTransformChecksum:
+		return checksumAddress(values[0])
+	case types.ColumnTransformConcat:
+		return strings.Join(values, derived.Separator), nil
+	default:
+		return "", fmt.Errorf("unknown transform %s", derived.Transform)
+	}
+}
+
+// bytesToString decodes hex encoded bytes as an UTF-8 string trimmed of zero bytes
+// (invalid UTF-8 bytes are dropped)
+func bytesToString(value string) (string, error) {
+	bytes, err := hex.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	str := strings.Trim(string(bytes), "\x00")
+	runes := make([]rune, 0, len(str))
+	for len(str) > 0 {
+		r, size := utf8.DecodeRuneInString(str)
+		if r != utf8.RuneError || size > 1 {
+			runes = append(runes, r)
+		}
+		str = str[size:]
+	}
+
+	return string(runes), nil
+}
+
+// scaleDecimals formats an integer as a fixed point number with the given decimals
+func scaleDecimals(value string, decimals int) (string, error) {
+	number, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid integer %s", value)
+	}
+
+	if decimals == 0 {
+		return number.String(), nil
+	}
+
+	sign := ""
+	if number.Sign() < 0 {
+		sign = "-"
+		number.Neg(number)
+	}
+
+	digits := number.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	return fmt.Sprintf("%s%s.%s", sign, digits[:len(digits)-decimals], digits[len(digits)-decimals:]), nil
+}
+
+// enumLabel returns the label of an enum ordinal
+func enumLabel(value string, labels []string) (string, error) {
+	ordinal, ok := new(big.Int).SetString(value, 10)
+	if !ok || ordinal.Sign() < 0 || !ordinal.IsInt64() || ordinal.Int64() >= int64(len(labels)) {
+		return "", fmt.Errorf("no label for enum value %s", value)
+	}
+
+	return labels[ordinal.Int64()], nil
+}
+
+// checksumAddress formats an address with the EIP-55 mixed case checksum
+func checksumAddress(value string) (string, error) {
+	address := strings.ToLower(value)
+	if _, err := hex.DecodeString(address); err != nil || len(address) != 40 {
+		return "", fmt.Errorf("invalid address %s", value)
+	}
+
+	hash := hex.EncodeToString(sha3.Sha3([]byte(address)))
+	checksum := []byte(address)
+
+	for i, c := range checksum {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			checksum[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksum), nil
+}
@@ -0,0 +1,118 @@
+package sqlsol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivedColumns(t *testing.T) {
+	derivedJSON := test.DerivedColumnsJSONConfFile(t)
+
+	byteValue := []byte(derivedJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	eventData := map[string]string{
+		"height": "10",
+		"symbol": "544F4B0000000000000000000000000000000000000000000000000000000000",
+		"owner":  "5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED",
+		"amount": "1500000000000000001",
+		"status": "1",
+	}
+
+	t.Run("successfully builds typed derived columns", func(t *testing.T) {
+		expected := map[string]types.SQLColumnType{
+			"symbol":     types.SQLColumnTypeVarchar,
+			"symbolText": types.SQLColumnTypeText,
+			"owner":      types.SQLColumnTypeVarchar,
+			"ownerHex":   types.SQLColumnTypeText,
+			"amount":     types.SQLColumnTypeNumeric,
+			"status":     types.SQLColumnTypeText,
+			"tokenKey":   types.SQLColumnTypeText,
+		}
+
+		for key, sqlType := range expected {
+			col, err := tableStruct.GetColumn("UpdateToken", key)
+			require.NoError(t, err)
+			require.Equal(t, sqlType, col.Type, key)
+		}
+
+		col, err := tableStruct.GetColumn("UpdateToken", "tokenKey")
+		require.NoError(t, err)
+		require.Equal(t, true, col.Primary)
+	})
+
+	t.Run("successfully computes derived columns values", func(t *testing.T) {
+		eventRows, err := tableStruct.GetEventRows("UpdateToken", eventData)
+		require.NoError(t, err)
+
+		row := eventRows["tokens"][0]
+		require.Equal(t, eventData["symbol"], row["symbolhex"])
+		require.Equal(t, "TOK", row["symbol"])
+		require.Equal(t, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", row["owner"])
+		require.Equal(t, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", row["ownerhex"])
+		require.Equal(t, "1.500000000000000001", row["amount"])
+		require.Equal(t, "Paused", row["status"])
+		require.Equal(t, eventData["symbol"]+":"+eventData["owner"]+":10", row["tokenkey"])
+	})
+
+	t.Run("successfully drops invalid UTF-8 bytes from decoded strings", func(t *testing.T) {
+		data := make(map[string]string)
+		for k, v := range eventData {
+			data[k] = v
+		}
+		// "T", invalid byte, "O", truncated 2 bytes sequence, "K", valid replacement character
+		data["symbol"] = "54FF4FC34BEFBFBD" + strings.Repeat("00", 24)
+
+		eventRows, err := tableStruct.GetEventRows("UpdateToken", data)
+		require.NoError(t, err)
+		require.Equal(t, "TOK\uFFFD", eventRows["tokens"][0]["symbol"])
+	})
+
+	t.Run("successfully scales small and negative values by decimals", func(t *testing.T) {
+		for amount, scaled := range map[string]string{"5": "0.000000000000000005", "0": "0.000000000000000000"} {
+			data := make(map[string]string)
+			for k, v := range eventData {
+				data[k] = v
+			}
+			data["amount"] = amount
+
+			eventRows, err := tableStruct.GetEventRows("UpdateToken", data)
+			require.NoError(t, err)
+			require.Equal(t, scaled, eventRows["tokens"][0]["amount"])
+		}
+	})
+
+	t.Run("returns an error if an enum value has no label", func(t *testing.T) {
+		data := make(map[string]string)
+		for k, v := range eventData {
+			data[k] = v
+		}
+		data["status"] = "3"
+
+		_, err := tableStruct.GetEventRows("UpdateToken", data)
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error if an input can not be transformed", func(t *testing.T) {
+		badJSON := strings.Replace(derivedJSON, `"transform" : "checksum"`, `"transform" : "decimals"`, 1)
+
+		_, err := sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+
+		badJSON = strings.Replace(derivedJSON, `"transform" : "checksum"`, `"transform" : "unknown"`, 1)
+
+		_, err = sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+
+		badJSON = strings.Replace(derivedJSON, `"inputs" : ["symbol"]`, `"inputs" : ["unknown"]`, 1)
+
+		_, err = sqlsol.NewParser([]byte(badJSON))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package sqlsol
+
+import (
+	"fmt"
+
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// ExceptionKey is the event data key of the exception of the execution of each event
+const ExceptionKey = "exception"
+
+// exceptionColumnName is the name of the column storing exceptions
+const exceptionColumnName = "exception"
+
+// getErrorsTableKey returns the key of the errors table of an event
+func getErrorsTableKey(eventName string) string {
+	return fmt.Sprintf("%s#errors", eventName)
+}
+
+// addExceptionColumn adds the exception column to an event table structure
+func addExceptionColumn(table types.SQLTable) (types.SQLTable, error) {
+	columns := make(map[string]types.SQLTableColumn, len(table.Columns)+1)
+	order := 0
+
+	for k, column := range table.Columns {
+		if k == ExceptionKey || column.Name == exceptionColumnName {
+			return types.SQLTable{}, fmt.Errorf("addExceptionColumn: table %s already has a %s column", table.Name, exceptionColumnName)
+		}
+		if column.Order > order {
+			order = column.Order
+		}
+		columns[k] = column
+	}
+
+	columns[ExceptionKey] = types.SQLTableColumn{
+		Name:    exceptionColumnName,
+		Type:    types.SQLColumnTypeText,
+		Primary: false,
+		Order:   order + 1,
+	}
+
+	return types.SQLTable{
+		Name:    table.Name,
+		Columns: columns,
+	}, nil
+}
+
+// getErrorsTable returns the structure of the table storing events of executions with exceptions,
+// the event table with the exception column
+func getErrorsTable(table types.SQLTable) (types.SQLTable, error) {
+	errorsTable, err := addExceptionColumn(table)
+	if err != nil {
+		return types.SQLTable{}, err
+	}
+
+	errorsTable.Name = fmt.Sprintf("%s_errors", table.Name)
+
+	return errorsTable, nil
+}
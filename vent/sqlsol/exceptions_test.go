@@ -0,0 +1,97 @@
+package sqlsol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExceptionPolicy(t *testing.T) {
+	exceptionJSON := test.ExceptionPolicyJSONConfFile(t)
+
+	byteValue := []byte(exceptionJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	eventData := func(exception string) map[string]string {
+		return map[string]string{
+			"height":            "10",
+			"key":               "1",
+			sqlsol.ExceptionKey: exception,
+		}
+	}
+
+	t.Run("successfully builds exception columns and errors tables", func(t *testing.T) {
+		require.Equal(t, true, tableStruct.StoresExceptions())
+
+		_, err := tableStruct.GetColumn("SkipEvent", sqlsol.ExceptionKey)
+		require.Error(t, err)
+
+		col, err := tableStruct.GetColumn("ColumnEvent", sqlsol.ExceptionKey)
+		require.NoError(t, err)
+		require.Equal(t, "exception", col.Name)
+		require.Equal(t, types.SQLColumnTypeText, col.Type)
+
+		tables := tableStruct.GetTables()
+		require.Equal(t, 4, len(tables))
+
+		_, err = tableStruct.GetColumn("TableEvent", sqlsol.ExceptionKey)
+		require.Error(t, err)
+	})
+
+	t.Run("successfully stores events of executions without exceptions in event tables", func(t *testing.T) {
+		for eventName, tableName := range map[string]string{"SkipEvent": "skipped", "ColumnEvent": "flagged", "TableEvent": "routed"} {
+			eventRows, err := tableStruct.GetEventRows(eventName, eventData(""))
+			require.NoError(t, err)
+			require.Equal(t, 1, len(eventRows[tableName]), eventName)
+		}
+	})
+
+	t.Run("successfully skips events of executions with exceptions by default", func(t *testing.T) {
+		eventRows, err := tableStruct.GetEventRows("SkipEvent", eventData("reverted"))
+		require.NoError(t, err)
+		require.Equal(t, 0, len(eventRows))
+	})
+
+	t.Run("successfully stores exceptions in the exception column", func(t *testing.T) {
+		eventRows, err := tableStruct.GetEventRows("ColumnEvent", eventData("reverted"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(eventRows))
+		require.Equal(t, "reverted", eventRows["flagged"][0]["exception"])
+		require.Equal(t, "1", eventRows["flagged"][0]["key"])
+	})
+
+	t.Run("successfully routes events of executions with exceptions to the errors table", func(t *testing.T) {
+		eventRows, err := tableStruct.GetEventRows("TableEvent", eventData("reverted"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(eventRows))
+		require.Equal(t, "reverted", eventRows["routed_errors"][0]["exception"])
+		require.Equal(t, "1", eventRows["routed_errors"][0]["key"])
+	})
+
+	t.Run("returns an error if the exception policy is unknown", func(t *testing.T) {
+		_, err := sqlsol.NewParser([]byte(strings.Replace(exceptionJSON, `"table"`, `"errors"`, 1)))
+		require.Error(t, err)
+	})
+
+	t.Run("returns an error if the event table already has an exception column", func(t *testing.T) {
+		_, err := sqlsol.NewParser([]byte(`[{
+			"TableName" : "Flagged",
+			"OnException" : "column",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{"indexed": false, "name": "key", "type": "uint256"}],
+				"name": "ColumnEvent",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "exception", "primary" : true}
+			}
+		}]`))
+		require.Error(t, err)
+	})
+}
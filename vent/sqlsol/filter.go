@@ -0,0 +1,158 @@
+package sqlsol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/burrow/event"
+	"github.com/hyperledger/burrow/event/query"
+	"github.com/hyperledger/burrow/execution/exec"
+)
+
+// logTextTags maps LOG0..LOG4 filter tags to burrow tags,
+// so they match the text (trimmed of zero bytes) written in each log topic
+var logTextTags = map[string]string{
+	"LOG0": exec.LogNTextKey(0),
+	"LOG1": exec.LogNTextKey(1),
+	"LOG2": exec.LogNTextKey(2),
+	"LOG3": exec.LogNTextKey(3),
+	"LOG4": exec.LogNTextKey(4),
+}
+
+// conditionsQuery is implemented by parsed queries able to list their conditions
+type conditionsQuery interface {
+	query.Query
+	Conditions() []query.Condition
+}
+
+// eventTags wraps event tags to resolve LOG0..LOG4 filter tags
+type eventTags struct {
+	query.Tagged
+}
+
+// Get returns the value of a given tag
+func (tags eventTags) Get(key string) (string, bool) {
+	if tag, ok := logTextTags[key]; ok {
+		key = tag
+	}
+	return tags.Tagged.Get(key)
+}
+
+// Matches returns true if the given event is emitted by one of the event contract addresses
+// (if any) and matches the event filter
+func (eventSpec EventSpec) Matches(ev *exec.Event) bool {
+	if len(eventSpec.Addresses) > 0 {
+		log := ev.GetLog()
+		if log == nil || !containsAddress(eventSpec.Addresses, log.Address) {
+			return false
+		}
+	}
+	if eventSpec.Filter == nil {
+		return true
+	}
+	return eventSpec.Filter.Matches(eventTags{Tagged: ev.Tagged()})
+}
+
+// getConditions returns the conditions an event must meet to match the event specification,
+// returns nil if all events match it
+func (eventSpec EventSpec) getConditions() []query.Condition {
+	var conditions []query.Condition
+
+	if qry, ok := eventSpec.Filter.(conditionsQuery); ok {
+		conditions = qry.Conditions()
+	}
+
+	// a single contract address can be expressed as a condition
+	if len(eventSpec.Addresses) == 1 {
+		conditions = append(conditions, query.Condition{
+			Tag:     event.AddressKey,
+			Op:      query.OpEqual,
+			Operand: eventSpec.Addresses[0].String(),
+		})
+	}
+
+	return conditions
+}
+
+// getFilter parses a sqlsol filter expression using burrow event query grammar
+func getFilter(filter string) (query.Query, error) {
+	if strings.TrimSpace(filter) == "" {
+		return query.Empty{}, nil
+	}
+
+	qry, err := query.New(filter)
+	if err != nil {
+		return nil, fmt.Errorf("getFilter: can not parse filter %s: %v", filter, err)
+	}
+
+	return qry, nil
+}
+
+// getSharedConditions returns the conditions shared by all given lists of conditions,
+// as filters can only be combined by conjunction these are the only
+// ones that can be pushed to the events server
+func getSharedConditions(conditionsList [][]query.Condition) []query.Condition {
+	var shared []query.Condition
+
+	for i, conditions := range conditionsList {
+		if len(conditions) == 0 {
+			// empty conditions match all events so there is nothing in common
+			return nil
+		}
+
+		if i == 0 {
+			shared = conditions
+			continue
+		}
+
+		var common []query.Condition
+		for _, condition := range shared {
+			if containsCondition(conditions, condition) {
+				common = append(common, condition)
+			}
+		}
+		shared = common
+	}
+
+	return shared
+}
+
+// containsCondition checks if a condition is in a list of conditions
+func containsCondition(conditions []query.Condition, condition query.Condition) bool {
+	for _, c := range conditions {
+		if c.Tag == condition.Tag && c.Op == condition.Op && c.Operand == condition.Operand {
+			return true
+		}
+	}
+	return false
+}
+
+// getConditionsQuery builds a query from the given conditions,
+// LOG0..LOG4 tags are translated to burrow tags
+func getConditionsQuery(conditions []query.Condition) *query.Builder {
+	qb := query.NewBuilder()
+
+	for _, condition := range conditions {
+		tag := condition.Tag
+		if logTag, ok := logTextTags[tag]; ok {
+			tag = logTag
+		}
+
+		switch condition.Op {
+		case query.OpEqual:
+			qb = qb.AndEquals(tag, condition.Operand)
+		case query.OpContains:
+			qb = qb.AndContains(tag, condition.Operand)
+		case query.OpGreater:
+			qb = qb.AndStrictlyGreaterThan(tag, condition.Operand)
+		case query.OpGreaterEqual:
+			qb = qb.AndGreaterThanOrEqual(tag, condition.Operand)
+		case query.OpLess:
+			qb = qb.AndStrictlyLessThan(tag, condition.Operand)
+		case query.OpLessEqual:
+			qb = qb.AndLessThanOrEqual(tag, condition.Operand)
+		}
+	}
+
+	return qb
+}
@@ -0,0 +1,143 @@
+package sqlsol_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches(t *testing.T) {
+	filterJSON := test.FilterJSONConfFile(t)
+
+	byteValue := []byte(filterJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	eventSpec, err := tableStruct.GetEventSpec("TEST_EVENTS")
+	require.NoError(t, err)
+
+	address, err := crypto.AddressFromHexString("4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51")
+	require.NoError(t, err)
+
+	t.Run("successfully matches an event by LOGn text and address", func(t *testing.T) {
+		event := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeLog},
+			Log: &exec.LogEvent{
+				Address: address,
+				Topics:  []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			},
+		}
+
+		require.Equal(t, true, eventSpec.Matches(event))
+	})
+
+	t.Run("does not match an event from another address", func(t *testing.T) {
+		event := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeLog},
+			Log: &exec.LogEvent{
+				Address: crypto.Address{},
+				Topics:  []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))},
+			},
+		}
+
+		require.Equal(t, false, eventSpec.Matches(event))
+	})
+
+	t.Run("does not match an event with another LOGn text", func(t *testing.T) {
+		event := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeLog},
+			Log: &exec.LogEvent{
+				Address: address,
+				Topics:  []binary.Word256{{}, binary.RightPadWord256([]byte("OTHER_EVENTS"))},
+			},
+		}
+
+		require.Equal(t, false, eventSpec.Matches(event))
+	})
+
+	t.Run("successfully matches an event by raw Logn topic", func(t *testing.T) {
+		topic := binary.RightPadWord256([]byte("TEST_EVENTS"))
+		rawJSON := strings.Replace(filterJSON, "LOG1 = 'TEST_EVENTS'", "Log1 = '"+strings.ToUpper(hex.EncodeToString(topic.Bytes()))+"'", 1)
+
+		rawStruct, err := sqlsol.NewParser([]byte(rawJSON))
+		require.NoError(t, err)
+
+		rawSpec, err := rawStruct.GetEventSpec("TEST_EVENTS")
+		require.NoError(t, err)
+
+		event := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeLog},
+			Log: &exec.LogEvent{
+				Address: address,
+				Topics:  []binary.Word256{{}, topic},
+			},
+		}
+
+		require.Equal(t, true, rawSpec.Matches(event))
+
+		// the raw topic is not compared as text
+		rawJSON = strings.Replace(filterJSON, "LOG1 = 'TEST_EVENTS'", "Log1 = 'TEST_EVENTS'", 1)
+
+		rawStruct, err = sqlsol.NewParser([]byte(rawJSON))
+		require.NoError(t, err)
+
+		rawSpec, err = rawStruct.GetEventSpec("TEST_EVENTS")
+		require.NoError(t, err)
+
+		require.Equal(t, false, rawSpec.Matches(event))
+	})
+
+	t.Run("successfully matches an event by the LOGn text of a good config file", func(t *testing.T) {
+		goodStruct, err := sqlsol.NewParser([]byte(test.GoodJSONConfFile(t)))
+		require.NoError(t, err)
+
+		goodSpec, err := goodStruct.GetEventSpec("UpdateUserAccount")
+		require.NoError(t, err)
+
+		event := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeLog},
+			Log: &exec.LogEvent{
+				Address: address,
+				Topics:  []binary.Word256{{}, binary.RightPadWord256([]byte("UserAccounts"))},
+			},
+		}
+
+		require.Equal(t, true, goodSpec.Matches(event))
+	})
+
+	t.Run("returns an error if the filter is malformed", func(t *testing.T) {
+		badFilterJSON := test.BadFilterJSONConfFile(t)
+
+		_, err := sqlsol.NewParser([]byte(badFilterJSON))
+		require.Error(t, err)
+	})
+}
+
+func TestGetEventsQuery(t *testing.T) {
+	t.Run("successfully builds a query with conditions shared by all filters", func(t *testing.T) {
+		filterJSON := test.FilterJSONConfFile(t)
+
+		tableStruct, err := sqlsol.NewParser([]byte(filterJSON))
+		require.NoError(t, err)
+
+		qry := tableStruct.GetEventsQuery()
+		require.Equal(t, "Address = '4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51'", qry.String())
+	})
+
+	t.Run("successfully builds an empty query if there are no shared conditions", func(t *testing.T) {
+		goodJSON := test.GoodJSONConfFile(t)
+
+		tableStruct, err := sqlsol.NewParser([]byte(goodJSON))
+		require.NoError(t, err)
+
+		qry := tableStruct.GetEventsQuery()
+		require.Equal(t, "", qry.String())
+	})
+}
@@ -0,0 +1,279 @@
+package sqlsol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/monax/bosmarmot/vent/types"
+)
+
+// maxNameLength is the maximum length of generated table and column names
+const maxNameLength = 60
+
+// primaryInputRegexp matches event input names usually holding identifiers
+var primaryInputRegexp = regexp.MustCompile(`(^|_)(?i:id|key)$|[a-z0-9](Id|ID|Key)$`)
+
+// sqlReservedWords can not be used as table or column names without quoting them
+var sqlReservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true, "array": true,
+	"as": true, "asc": true, "asymmetric": true, "authorization": true, "binary": true, "both": true,
+	"case": true, "cast": true, "check": true, "collate": true, "collation": true, "column": true,
+	"concurrently": true, "constraint": true, "create": true, "cross": true, "current_catalog": true,
+	"current_date": true, "current_role": true, "current_schema": true, "current_time": true,
+	"current_timestamp": true, "current_user": true, "default": true, "deferrable": true, "desc": true,
+	"distinct": true, "do": true, "else": true, "end": true, "except": true, "false": true,
+	"fetch": true, "for": true, "foreign": true, "freeze": true, "from": true, "full": true,
+	"grant": true, "group": true, "having": true, "ilike": true, "in": true, "initially": true,
+	"inner": true, "intersect": true, "into": true, "is": true, "isnull": true, "join": true,
+	"lateral": true, "leading": true, "left": true, "like": true, "limit": true, "localtime": true,
+	"localtimestamp": true, "natural": true, "not": true, "notnull": true, "null": true, "offset": true,
+	"on": true, "only": true, "or": true, "order": true, "outer": true, "overlaps": true,
+	"placing": true, "primary": true, "references": true, "returning": true, "right": true,
+	"select": true, "session_user": true, "similar": true, "some": true, "symmetric": true,
+	"table": true, "tablesample": true, "then": true, "to": true, "trailing": true, "true": true,
+	"union": true, "unique": true, "user": true, "using": true, "variadic": true, "verbose": true,
+	"when": true, "where": true, "window": true, "with": true,
+}
+
+// abiContract holds contract ABIs found in compiler outputs
+// (solc and burrow deploy outputs hold the ABI in the abi field or in a contracts map)
+type abiContract struct {
+	Abi       json.RawMessage            `json:"abi"`
+	Contracts map[string]json.RawMessage `json:"contracts"`
+}
+
+// GenerateEventDefinitions reads contract ABIs from a solc or burrow deploy output
+// (or a plain ABI file) and builds a starter event definition for each event,
+// table and column names are derived from contract, event and input names,
+// and primary keys are guessed from input names and indexed inputs
+func GenerateEventDefinitions(contractName string, byteValue []byte) ([]types.EventDefinition, error) {
+	contracts := make(map[string][]types.Event)
+
+	if err := readContractEvents(contractName, byteValue, contracts); err != nil {
+		return nil, fmt.Errorf("GenerateEventDefinitions: can not read ABI of contract %s: %v", contractName, err)
+	}
+
+	// sort contracts to generate stable outputs
+	contractNames := make([]string, 0, len(contracts))
+	for name := range contracts {
+		contractNames = append(contractNames, name)
+	}
+	sort.Strings(contractNames)
+
+	var eventDefs []types.EventDefinition
+
+	for _, name := range contractNames {
+		for _, event := range contracts[name] {
+			eventDefs = append(eventDefs, generateEventDefinition(name, event))
+		}
+	}
+
+	return eventDefs, nil
+}
+
+// readContractEvents reads events from ABIs in compiler outputs into a map of contract events
+func readContractEvents(contractName string, byteValue []byte, contracts map[string][]types.Event) error {
+	byteValue = bytes.TrimSpace(byteValue)
+
+	if len(byteValue) == 0 {
+		return fmt.Errorf("empty ABI")
+	}
+
+	switch byteValue[0] {
+	case '[':
+		// plain ABI
+		var abiSpec []types.Event
+		if err := json.Unmarshal(byteValue, &abiSpec); err != nil {
+			return err
+		}
+
+		for _, entry := range abiSpec {
+			// events without inputs have no data to store, and anonymous events
+			// can not be matched until contract addresses are given
+			if entry.Type == "event" && len(entry.Inputs) > 0 && !entry.Anonymous {
+				contracts[contractName] = append(contracts[contractName], entry)
+			}
+		}
+		return nil
+
+	case '"':
+		// ABI encoded as a JSON string (solc combined json)
+		var abiSpec string
+		if err := json.Unmarshal(byteValue, &abiSpec); err != nil {
+			return err
+		}
+		return readContractEvents(contractName, []byte(abiSpec), contracts)
+
+	case '{':
+		var contract abiContract
+		if err := json.Unmarshal(byteValue, &contract); err != nil {
+			return err
+		}
+
+		if len(contract.Abi) > 0 {
+			return readContractEvents(contractName, contract.Abi, contracts)
+		}
+
+		if len(contract.Contracts) == 0 {
+			return fmt.Errorf("no abi or contracts found")
+		}
+
+		for name, raw := range contract.Contracts {
+			// solc combined json uses file:contract names
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				name = name[i+1:]
+			}
+
+			var entry abiContract
+			if err := json.Unmarshal(raw, &entry); err == nil && len(entry.Abi) == 0 {
+				// solc standard json maps source files to contracts
+				var fileContracts map[string]json.RawMessage
+				if err := json.Unmarshal(raw, &fileContracts); err != nil {
+					return err
+				}
+
+				for fileContractName, fileContract := range fileContracts {
+					if err := readContractEvents(fileContractName, fileContract, contracts); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if err := readContractEvents(name, raw, contracts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown ABI format")
+}
+
+// generateEventDefinition builds the event definition of a contract event
+func generateEventDefinition(contractName string, event types.Event) types.EventDefinition {
+	tableName := toSnakeCase(event.Name)
+	if contractName != "" {
+		tableName = toSnakeCase(contractName) + "_" + tableName
+	}
+
+	// global columns (and their event data keys) can not be used by event inputs
+	usedNames := make(map[string]bool)
+	usedInputs := make(map[string]bool)
+	for key, column := range getGlobalColumns() {
+		usedNames[column.Name] = true
+		usedInputs[key] = true
+	}
+
+	inputs := make([]types.EventInput, len(event.Inputs))
+	columns := make(map[string]types.EventColumn)
+
+	for i, input := range event.Inputs {
+		// unnamed inputs are allowed by Solidity
+		if input.Name == "" {
+			input.Name = fmt.Sprintf("arg%d", i)
+		}
+		// input names are not part of event signatures
+		for usedInputs[input.Name] {
+			input.Name += "_"
+		}
+		usedInputs[input.Name] = true
+		inputs[i] = input
+
+		columnName := truncateName(toSnakeCase(input.Name))
+		for usedNames[columnName] || sqlReservedWords[columnName] {
+			columnName = truncateName(columnName + "_")
+		}
+		usedNames[columnName] = true
+
+		columns[input.Name] = types.EventColumn{Name: columnName}
+	}
+
+	for _, name := range guessPrimaryInputs(inputs) {
+		column := columns[name]
+		column.Primary = true
+		columns[name] = column
+	}
+
+	event.Inputs = inputs
+
+	tableName = truncateName(tableName)
+	if sqlReservedWords[tableName] {
+		tableName += "_"
+	}
+
+	return types.EventDefinition{
+		TableName: tableName,
+		Event:     event,
+		Columns:   columns,
+	}
+}
+
+// guessPrimaryInputs returns the inputs used as primary keys:
+// inputs named like identifiers, or indexed inputs, or the first input
+func guessPrimaryInputs(inputs []types.EventInput) []string {
+	var named, indexed []string
+
+	for _, input := range inputs {
+		evType, err := input.ParseType()
+		// arrays and tuples are only used if indexed (stored as hashes)
+		if err != nil || (!input.Indexed && (evType.IsArray() || evType.IsTuple())) {
+			continue
+		}
+
+		if primaryInputRegexp.MatchString(input.Name) {
+			named = append(named, input.Name)
+		}
+		if input.Indexed {
+			indexed = append(indexed, input.Name)
+		}
+	}
+
+	switch {
+	case len(named) > 0:
+		return named
+	case len(indexed) > 0:
+		return indexed
+	case len(inputs) > 0:
+		return []string{inputs[0].Name}
+	default:
+		return nil
+	}
+}
+
+// toSnakeCase converts names (i.e. camel case ones) to lower case snake case
+func toSnakeCase(name string) string {
+	var buf bytes.Buffer
+	runes := []rune(name)
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			r = '_'
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				buf.WriteRune('_')
+			}
+		}
+
+		buf.WriteRune(unicode.ToLower(r))
+	}
+
+	return strings.Trim(buf.String(), "_")
+}
+
+// truncateName truncates names to the maximum length of table and column names
+func truncateName(name string) string {
+	if len(name) > maxNameLength {
+		return name[:maxNameLength]
+	}
+	return name
+}
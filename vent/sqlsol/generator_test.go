@@ -0,0 +1,90 @@
+package sqlsol_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateEventDefinitions(t *testing.T) {
+	abiJSON := test.ABIJSONFile(t)
+
+	t.Run("successfully generates an events configuration loaded by the parser", func(t *testing.T) {
+		eventDefs, err := sqlsol.GenerateEventDefinitions("EventsTest", []byte(abiJSON))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(eventDefs))
+
+		byteValue, err := json.MarshalIndent(eventDefs, "", "  ")
+		require.NoError(t, err)
+
+		tableStruct, err := sqlsol.NewParser(byteValue)
+		require.NoError(t, err)
+
+		tableName, err := tableStruct.GetTableName("UpdateTestEvents")
+		require.NoError(t, err)
+		require.Equal(t, "events_test_update_test_events", tableName)
+
+		tableName, err = tableStruct.GetTableName("UpdateUserAccount")
+		require.NoError(t, err)
+		require.Equal(t, "events_test_update_user_account", tableName)
+
+		// identifiers are guessed as primary keys
+		col, err := tableStruct.GetColumn("UpdateTestEvents", "key")
+		require.NoError(t, err)
+		require.Equal(t, "key", col.Name)
+		require.Equal(t, true, col.Primary)
+
+		col, err = tableStruct.GetColumn("UpdateTestEvents", "name")
+		require.NoError(t, err)
+		require.Equal(t, false, col.Primary)
+
+		// otherwise indexed inputs are guessed as primary keys
+		col, err = tableStruct.GetColumn("UpdateUserAccount", "userAddress")
+		require.NoError(t, err)
+		require.Equal(t, "user_address", col.Name)
+		require.Equal(t, true, col.Primary)
+
+		// global column names are not used by inputs
+		col, err = tableStruct.GetColumn("UpdateUserAccount", "index_")
+		require.NoError(t, err)
+		require.Equal(t, "index_", col.Name)
+
+		eventSpec, err := tableStruct.GetEventSpec("UpdateUserAccount")
+		require.NoError(t, err)
+		require.Equal(t, "UpdateUserAccount(string,address,uint256,uint256[],(address,bool),address)", eventSpec.Event.Signature())
+
+		// SQL reserved words are not used as column names
+		col, err = tableStruct.GetColumn("UpdateUserAccount", "to")
+		require.NoError(t, err)
+		require.Equal(t, "to_", col.Name)
+
+		col, err = tableStruct.GetColumn("UpdateUserAccount", "arg3")
+		require.NoError(t, err)
+		require.Equal(t, "arg3", col.Name)
+	})
+
+	t.Run("successfully reads ABIs from burrow deploy and solc outputs", func(t *testing.T) {
+		// contract names are read from solc outputs (burrow deploy outputs are named after the file)
+		outputs := map[string]string{
+			"EventsTest": fmt.Sprintf(`{"Abi": %s, "Evm": {"Bytecode": {"Object": "6060"}}}`, abiJSON),
+			"combined":   fmt.Sprintf(`{"contracts": {"EventsTest.sol:EventsTest": {"abi": %q}}}`, abiJSON),
+			"standard":   fmt.Sprintf(`{"contracts": {"EventsTest.sol": {"EventsTest": {"abi": %s}}}}`, abiJSON),
+		}
+
+		for contractName, output := range outputs {
+			eventDefs, err := sqlsol.GenerateEventDefinitions(contractName, []byte(output))
+			require.NoError(t, err)
+			require.Equal(t, 2, len(eventDefs))
+			require.Equal(t, "events_test_update_test_events", eventDefs[0].TableName)
+		}
+	})
+
+	t.Run("returns an error if the ABI can not be read", func(t *testing.T) {
+		_, err := sqlsol.GenerateEventDefinitions("EventsTest", []byte(`{"bytecode": "6060"}`))
+		require.Error(t, err)
+	})
+}
@@ -3,27 +3,71 @@ package sqlsol
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/event/query"
+	"github.com/hyperledger/burrow/execution/evm/sha3"
+	"github.com/hyperledger/burrow/execution/exec"
 	"github.com/monax/bosmarmot/vent/types"
 )
 
+// BlockTimeKey is the event data key of the time of the block of each event
+const BlockTimeKey = "blockTime"
+
+// blockTimeColumnName is the name of the column storing block times in event tables
+const blockTimeColumnName = "blocktime"
+
 // Parser contains EventTable definition
 type Parser struct {
 	// maps event names to tables
+	// (events defined more than once are mapped by event name and table name)
 	Tables types.EventTables
+	// maps event names to event specifications
+	EventSpecs map[string]EventSpec
+	// maps event signature hashes to event names
+	EventIDs map[binary.Word256][]string
+}
+
+// EventSpec contains the specification of an event,
+// used to match logs and to decode its inputs from log topics and data
+type EventSpec struct {
+	Name           string
+	ID             binary.Word256
+	EventNameTopic bool
+	Event          types.Event
+	Filter         query.Query
+	Addresses      []crypto.Address
+	// maps array inputs expanded into child tables to child table keys
+	ChildTables map[string]string
+	// columns computed from event data
+	DerivedColumns []DerivedColumn
+	// how events of executions with exceptions are stored
+	OnException string
 }
 
 // NewParser receives a sqlsol event configuration stream
 // and returns a pointer to a filled parser structure
 func NewParser(byteValue []byte) (*Parser, error) {
-	tables, err := mapToTable(byteValue)
+	tables, eventSpecs, err := mapToTable(byteValue)
 	if err != nil {
 		return nil, err
 	}
 
+	eventIDs := make(map[binary.Word256][]string)
+
+	for eventName, eventSpec := range eventSpecs {
+		if !eventSpec.EventNameTopic && !eventSpec.Event.Anonymous {
+			eventIDs[eventSpec.ID] = append(eventIDs[eventSpec.ID], eventName)
+		}
+	}
+
 	return &Parser{
-		Tables: tables,
+		Tables:     tables,
+		EventSpecs: eventSpecs,
+		EventIDs:   eventIDs,
 	}, nil
 }
 
@@ -32,6 +76,33 @@ func (p *Parser) GetTables() types.EventTables {
 	return p.Tables
 }
 
+// AddBlockTimeColumn adds the blocktime column (time of the block of each event)
+// to every event table, it is filled with event data under BlockTimeKey
+func (p *Parser) AddBlockTimeColumn() error {
+	for key, table := range p.Tables {
+		order := 0
+
+		for k, column := range table.Columns {
+			if k == BlockTimeKey || column.Name == blockTimeColumnName {
+				return fmt.Errorf("AddBlockTimeColumn: table %s already has a %s column", table.Name, blockTimeColumnName)
+			}
+			if column.Order > order {
+				order = column.Order
+			}
+		}
+
+		table.Columns[BlockTimeKey] = types.SQLTableColumn{
+			Name:    blockTimeColumnName,
+			Type:    types.SQLColumnTypeTimeStamp,
+			Primary: false,
+			Order:   order + 1,
+		}
+		p.Tables[key] = table
+	}
+
+	return nil
+}
+
 // GetTableName receives an eventName and returns the mapping tableName
 func (p *Parser) GetTableName(eventName string) (string, error) {
 	if table, ok := p.Tables[eventName]; ok {
@@ -41,6 +112,169 @@ func (p *Parser) GetTableName(eventName string) (string, error) {
 	return "", fmt.Errorf("GetTableName: eventName does not exists as a table in SQL table structure: %s ", eventName)
 }
 
+// GetEventSpec receives an eventName and returns the mapping event specification
+func (p *Parser) GetEventSpec(eventName string) (EventSpec, error) {
+	if eventSpec, ok := p.EventSpecs[eventName]; ok {
+		return eventSpec, nil
+	}
+
+	return EventSpec{}, fmt.Errorf("GetEventSpec: eventName does not exists as an event in event specifications: %s ", eventName)
+}
+
+// GetEventNames receives a log and returns the mapping eventNames,
+// logs are matched by event signature hash in Topics[0] (along with the topics/data shape of the event),
+// or by event name in Topics[1] for events defined that way,
+// logs of anonymous events are matched by contract address and topics/data shape
+// (unless Topics[0] is the signature hash of a non anonymous event)
+func (p *Parser) GetEventNames(log *exec.LogEvent) []string {
+	var eventNames []string
+	var signed bool
+
+	// events sharing a signature can differ in which inputs are indexed (i.e. ERC-20 and ERC-721 Transfer),
+	// so the topics/data shape of the log is checked too
+	if len(log.Topics) > 0 {
+		for _, eventName := range p.EventIDs[log.Topics[0]] {
+			signed = true
+			if p.EventSpecs[eventName].matchesShape(log) {
+				eventNames = append(eventNames, eventName)
+			}
+		}
+	}
+
+	if len(log.Topics) > 1 {
+		name := strings.Trim(log.Topics[1].String(), "\x00")
+		for eventName, eventSpec := range p.EventSpecs {
+			if eventSpec.EventNameTopic && eventSpec.Name == name {
+				eventNames = append(eventNames, eventName)
+			}
+		}
+	}
+
+	if !signed {
+		for eventName, eventSpec := range p.EventSpecs {
+			if eventSpec.Event.Anonymous && containsAddress(eventSpec.Addresses, log.Address) && eventSpec.matchesShape(log) {
+				eventNames = append(eventNames, eventName)
+			}
+		}
+	}
+
+	return eventNames
+}
+
+// GetChildTables receives an eventName and returns its array inputs
+// expanded into child tables, mapped to child table keys
+// (child table keys can be used as event names to get child tables and columns)
+func (p *Parser) GetChildTables(eventName string) map[string]string {
+	if eventSpec, ok := p.EventSpecs[eventName]; ok {
+		return eventSpec.ChildTables
+	}
+	return nil
+}
+
+// GetEventRows receives an eventName and decoded event data and returns rows
+// (with data mapped to SQL columnNames) by tableName, a row for the event table
+// and a row for each element of the array inputs expanded into child tables,
+// event inputs without a column definition are not stored,
+// events of executions with exceptions are handled by the exception policy of the event
+func (p *Parser) GetEventRows(eventName string, eventData map[string]string) (map[string]types.EventDataTable, error) {
+	tableName, err := p.GetTableName(eventName)
+	if err != nil {
+		return nil, err
+	}
+
+	eventSpec := p.EventSpecs[eventName]
+
+	// add derived columns values
+	if eventData, err = addDerivedData(eventSpec.DerivedColumns, eventData); err != nil {
+		return nil, err
+	}
+
+	eventRows := make(map[string]types.EventDataTable)
+
+	if eventData[ExceptionKey] != "" {
+		switch eventSpec.OnException {
+		case types.ExceptionPolicyColumn:
+			// stored as any other event, along with its exception
+		case types.ExceptionPolicyTable:
+			// only the event row is stored in the errors table
+			errorsTableKey := getErrorsTableKey(eventName)
+
+			errorsTableName, err := p.GetTableName(errorsTableKey)
+			if err != nil {
+				return nil, err
+			}
+
+			eventRows[errorsTableName] = types.EventDataTable{p.getRow(errorsTableKey, eventData)}
+			return eventRows, nil
+		default:
+			return eventRows, nil
+		}
+	}
+	eventRows[tableName] = types.EventDataTable{p.getRow(eventName, eventData)}
+
+	for input, childTableKey := range p.GetChildTables(eventName) {
+		childTableName, err := p.GetTableName(childTableKey)
+		if err != nil {
+			return nil, err
+		}
+
+		elements, err := getArrayElements(eventData[input])
+		if err != nil {
+			return nil, err
+		}
+
+		// child rows share event data, but the array input holds each element
+		for i, element := range elements {
+			childData := make(map[string]string, len(eventData)+1)
+			for k, v := range eventData {
+				childData[k] = v
+			}
+			childData[input] = element
+			childData[OrdinalKey] = strconv.Itoa(i)
+
+			eventRows[childTableName] = append(eventRows[childTableName], p.getRow(childTableKey, childData))
+		}
+	}
+
+	return eventRows, nil
+}
+
+// getRow maps event data to SQL columnNames
+func (p *Parser) getRow(eventName string, eventData map[string]string) types.EventDataRow {
+	row := make(types.EventDataRow)
+
+	for k, v := range eventData {
+		if columnName, err := p.GetColumnName(eventName, k); err == nil {
+			row[columnName] = v
+		}
+	}
+
+	return row
+}
+
+// StoresExceptions returns true if events of executions with exceptions are stored for any event
+func (p *Parser) StoresExceptions() bool {
+	for _, eventSpec := range p.EventSpecs {
+		if eventSpec.OnException == types.ExceptionPolicyColumn || eventSpec.OnException == types.ExceptionPolicyTable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetEventsQuery returns a query with the filter conditions shared by all events,
+// so events not matching any filter can be discarded by the events server
+func (p *Parser) GetEventsQuery() *query.Builder {
+	conditions := make([][]query.Condition, 0, len(p.EventSpecs))
+
+	for _, eventSpec := range p.EventSpecs {
+		conditions = append(conditions, eventSpec.getConditions())
+	}
+
+	return getConditionsQuery(getSharedConditions(conditions))
+}
+
 // GetColumnName receives an event Name and item and returns the mapping columnName
 func (p *Parser) GetColumnName(eventName, eventItem string) (string, error) {
 	if table, ok := p.Tables[eventName]; ok {
@@ -80,78 +314,213 @@ func (p *Parser) SetTableName(eventName, tableName string) error {
 
 // mapToTable gets a sqlsol event configuration stream,
 // parses contents, maps event types to SQL column types
-// and fills Event table structure with table and columns info
-func mapToTable(byteValue []byte) (map[string]types.SQLTable, error) {
+// and fills Event table structure with table and columns info,
+// it also builds event specifications to match logs and decode event inputs
+func mapToTable(byteValue []byte) (map[string]types.SQLTable, map[string]EventSpec, error) {
 	tables := make(map[string]types.SQLTable)
+	eventSpecs := make(map[string]EventSpec)
 	eventsDefinition := []types.EventDefinition{}
 
 	// parses json config stream
 	if err := json.Unmarshal(byteValue, &eventsDefinition); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// obtain global SQL table columns to add to columns definition map
 	globalColumns := getGlobalColumns()
 	globalColumnsLength := len(globalColumns)
 
+	// count event definitions by event name
+	// (same events can be mapped to different tables, i.e. scoped by contract address)
+	eventDefs := make(map[string]int)
+	for _, eventDef := range eventsDefinition {
+		eventDefs[eventDef.Event.Name]++
+	}
+
+	// cache of already read deploy output files
+	deployOutputs := make(map[string]map[string]interface{})
+
 	for _, eventDef := range eventsDefinition {
 		// validate json structure
 		if err := eventDef.Validate(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// if it is an event
 		if eventDef.Event.Type == "event" {
+			eventName := eventDef.Event.Name
+			if eventDefs[eventName] > 1 {
+				eventName = fmt.Sprintf("%s:%s", eventDef.Event.Name, strings.ToLower(eventDef.TableName))
+			}
+
+			if _, ok := tables[eventName]; ok {
+				return nil, nil, fmt.Errorf("mapToTable: event %s is mapped more than once to table %s", eventDef.Event.Name, eventDef.TableName)
+			}
+
 			// build columns mapping
 			columns := make(map[string]types.SQLTableColumn)
+			order := globalColumnsLength
 
-			for i, eventInput := range eventDef.Event.Inputs {
-				if col, ok := eventDef.Columns[eventInput.Name]; ok {
+			for _, eventInput := range eventDef.Event.Inputs {
+				if col, ok := eventDef.Columns[eventInput.Name]; ok && !col.ChildTable && col.Transform == "" {
 
-					sqlType, sqlTypeLength, err := getSQLType(eventInput.Type)
+					sqlType, sqlTypeLength, err := getSQLType(eventInput)
 					if err != nil {
-						return nil, err
+						return nil, nil, err
 					}
 
+					order++
 					columns[eventInput.Name] = types.SQLTableColumn{
 						Name:    col.Name,
 						Type:    sqlType,
 						Length:  sqlTypeLength,
 						Primary: col.Primary,
-						Order:   i + (globalColumnsLength + 1),
+						Order:   order,
 					}
 				}
 			}
 
+			// build derived columns
+			derivedColumns, derivedTableColumns, err := getDerivedColumns(eventDef)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for _, derived := range derivedColumns {
+				order++
+				column := derivedTableColumns[derived.Key]
+				column.Order = order
+				columns[derived.Key] = column
+			}
+
 			// add global columns to column definition
 			for k, v := range globalColumns {
 				columns[k] = v
 			}
 
-			tables[eventDef.Event.Name] = types.SQLTable{
+			tables[eventName] = types.SQLTable{
 				Name:    strings.ToLower(eventDef.TableName),
 				Columns: columns,
 			}
+
+			// events of executions with exceptions are stored with their exception
+			// in the event table or in its errors table (if requested)
+			switch eventDef.OnException {
+			case types.ExceptionPolicyColumn:
+				if tables[eventName], err = addExceptionColumn(tables[eventName]); err != nil {
+					return nil, nil, err
+				}
+			case types.ExceptionPolicyTable:
+				if tables[getErrorsTableKey(eventName)], err = getErrorsTable(tables[eventName]); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			// build child tables for expanded array inputs
+			childTables := make(map[string]string)
+
+			for _, eventInput := range eventDef.Event.Inputs {
+				if col, ok := eventDef.Columns[eventInput.Name]; ok && col.ChildTable && col.Transform == "" {
+					childTable, err := getChildTable(tables[eventName], eventInput, col, globalColumns)
+					if err != nil {
+						return nil, nil, err
+					}
+
+					childTableKey := getChildTableKey(eventName, eventInput.Name)
+					childTables[eventInput.Name] = childTableKey
+					tables[childTableKey] = childTable
+				}
+			}
+
+			// parse event filter
+			filter, err := getFilter(eventDef.Filter)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// parse contract addresses
+			addresses, err := getAddresses(eventDef.Addresses, eventDef.DeployFile, deployOutputs)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// anonymous events have no signature topic so they are matched
+			// by contract address and topics/data shape
+			if eventDef.Event.Anonymous && (len(addresses) == 0 || eventDef.EventNameTopic) {
+				return nil, nil, fmt.Errorf("mapToTable: anonymous event %s must be matched by contract addresses (without EventNameTopic)", eventDef.Event.Name)
+			}
+
+			eventSpecs[eventName] = EventSpec{
+				Name:           eventDef.Event.Name,
+				ID:             getEventID(eventDef.Event),
+				EventNameTopic: eventDef.EventNameTopic,
+				Event:          eventDef.Event,
+				Filter:         filter,
+				Addresses:      addresses,
+				ChildTables:    childTables,
+				DerivedColumns: derivedColumns,
+				OnException:    eventDef.OnException,
+			}
 		}
 	}
 
-	return tables, nil
+	return tables, eventSpecs, nil
+}
+
+// getEventID returns the keccak256 hash of the event signature,
+// stored by non anonymous events in the first log topic
+func getEventID(event types.Event) binary.Word256 {
+	return binary.LeftPadWord256(sha3.Sha3([]byte(event.Signature())))
 }
 
 // getSQLType maps event input types with corresponding
-// SQL column types
-func getSQLType(eventInputType string) (types.SQLColumnType, int, error) {
-	switch strings.ToLower(eventInputType) {
+// SQL column types, mapping is lossless:
+// - integers are stored as INTEGER, BIGINT or NUMERIC(78) depending on their size
+// - bytesN and dynamic bytes are stored as hex strings
+// - arrays and tuples are stored as JSON values
+// - indexed inputs not stored as is in log topics are stored as the hex string of their keccak256 hash
+func getSQLType(eventInput types.EventInput) (types.SQLColumnType, int, error) {
+	evType, err := eventInput.ParseType()
+	if err != nil {
+		return 0, 0, fmt.Errorf("getSQLType: don't know how to map eventInputType: %s ", eventInput.Type)
+	}
+
+	if eventInput.Indexed && !evType.IsValueType() {
+		return types.SQLColumnTypeVarchar, 64, nil
+	}
+
+	if evType.IsArray() || evType.IsTuple() {
+		return types.SQLColumnTypeJSON, 0, nil
+	}
+
+	switch evType.Base {
 	case types.EventInputTypeInt, types.EventInputTypeUInt:
-		return types.SQLColumnTypeInt, 0, nil
-	case types.EventInputTypeAddress, types.EventInputTypeBytes:
+		// signed integers use all the bits of the SQL type
+		bits := evType.Size
+		if evType.Base == types.EventInputTypeUInt {
+			bits++
+		}
+		switch {
+		case bits <= 32:
+			return types.SQLColumnTypeInt, 0, nil
+		case bits <= 64:
+			return types.SQLColumnTypeBigInt, 0, nil
+		default:
+			return types.SQLColumnTypeNumeric, 78, nil
+		}
+	case types.EventInputTypeAddress:
 		return types.SQLColumnTypeVarchar, 100, nil
+	case types.EventInputTypeBytes:
+		if evType.Size > 0 {
+			return types.SQLColumnTypeVarchar, evType.Size * 2, nil
+		}
+		return types.SQLColumnTypeText, 0, nil
 	case types.EventInputTypeBool:
 		return types.SQLColumnTypeBool, 0, nil
 	case types.EventInputTypeString:
 		return types.SQLColumnTypeText, 0, nil
 	default:
-		return 0, 0, fmt.Errorf("getSQLType: don't know how to map eventInputType: %s ", eventInputType)
+		return 0, 0, fmt.Errorf("getSQLType: don't know how to map eventInputType: %s ", eventInput.Type)
 	}
 }
 
@@ -203,5 +572,13 @@ func getGlobalColumns() map[string]types.SQLTableColumn {
 		Order:   5,
 	}
 
+	globalColumns["contractAddress"] = types.SQLTableColumn{
+		Name:    "contractaddress",
+		Type:    types.SQLColumnTypeVarchar,
+		Length:  100,
+		Primary: false,
+		Order:   6,
+	}
+
 	return globalColumns
 }
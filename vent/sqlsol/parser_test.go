@@ -4,6 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/execution/evm/sha3"
+	"github.com/hyperledger/burrow/execution/exec"
 	"github.com/monax/bosmarmot/vent/sqlsol"
 	"github.com/monax/bosmarmot/vent/test"
 	"github.com/monax/bosmarmot/vent/types"
@@ -94,6 +97,73 @@ func TestGetTableName(t *testing.T) {
 	})
 }
 
+func TestGetEventNames(t *testing.T) {
+	goodJSON := test.GoodJSONConfFile(t)
+
+	byteValue := []byte(goodJSON)
+	tableStruct, _ := sqlsol.NewParser(byteValue)
+
+	t.Run("successfully gets the event name for a given event signature hash", func(t *testing.T) {
+		eventID := binary.LeftPadWord256(sha3.Sha3([]byte("UpdateUserAccount(string,address,uint256)")))
+		log := &exec.LogEvent{Topics: []binary.Word256{eventID}, Data: make([]byte, 3*32)}
+
+		eventNames := tableStruct.GetEventNames(log)
+		require.Equal(t, []string{"UpdateUserAccount"}, eventNames)
+	})
+
+	t.Run("successfully gets the event name for a given event name topic", func(t *testing.T) {
+		log := &exec.LogEvent{Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("TEST_EVENTS"))}}
+
+		eventNames := tableStruct.GetEventNames(log)
+		require.Equal(t, []string{"TEST_EVENTS"}, eventNames)
+	})
+
+	t.Run("unsuccessfully gets the event name for an event name topic of a signature matched event", func(t *testing.T) {
+		log := &exec.LogEvent{Topics: []binary.Word256{{}, binary.RightPadWord256([]byte("UpdateUserAccount"))}}
+
+		eventNames := tableStruct.GetEventNames(log)
+		require.Equal(t, 0, len(eventNames))
+	})
+
+	t.Run("unsuccessfully gets the event name for an unknown event signature hash", func(t *testing.T) {
+		eventID := binary.LeftPadWord256(sha3.Sha3([]byte("UpdateUserAccount(string,address,uint8)")))
+		log := &exec.LogEvent{Topics: []binary.Word256{eventID}}
+
+		eventNames := tableStruct.GetEventNames(log)
+		require.Equal(t, 0, len(eventNames))
+	})
+
+	t.Run("unsuccessfully gets the event name for a log with another topics/data shape of the same signature", func(t *testing.T) {
+		// an ERC-20 Transfer definition, whose signature is shared by ERC-721 Transfer with an indexed token id
+		transferJSON := strings.Replace(test.AnonymousJSONConfFile(t), `"anonymous": true`, `"anonymous": false`, 1)
+
+		tableStruct, err := sqlsol.NewParser([]byte(transferJSON))
+		require.NoError(t, err)
+
+		eventID := binary.LeftPadWord256(sha3.Sha3([]byte("Transfer(address,address,uint256)")))
+		addressTopic := binary.LeftPadWord256([]byte{0x01})
+
+		log := &exec.LogEvent{Topics: []binary.Word256{eventID, addressTopic, addressTopic}, Data: make([]byte, 32)}
+		require.Equal(t, []string{"Transfer"}, tableStruct.GetEventNames(log))
+
+		log = &exec.LogEvent{Topics: []binary.Word256{eventID, addressTopic, addressTopic, binary.LeftPadWord256([]byte{0x2A})}}
+		require.Equal(t, 0, len(tableStruct.GetEventNames(log)))
+	})
+
+	t.Run("successfully gets every event name for an event mapped to several tables", func(t *testing.T) {
+		addressesJSON := test.AddressesJSONConfFile(t)
+
+		tableStruct, err := sqlsol.NewParser([]byte(addressesJSON))
+		require.NoError(t, err)
+
+		eventID := binary.LeftPadWord256(sha3.Sha3([]byte("UpdateUserAccount(string,address,uint256)")))
+		log := &exec.LogEvent{Topics: []binary.Word256{eventID}, Data: make([]byte, 3*32)}
+
+		eventNames := tableStruct.GetEventNames(log)
+		require.ElementsMatch(t, []string{"UpdateUserAccount:useraccounts", "UpdateUserAccount:otheraccounts"}, eventNames)
+	})
+}
+
 func TestGetColumnName(t *testing.T) {
 	goodJSON := test.GoodJSONConfFile(t)
 
@@ -144,6 +214,77 @@ func TestGetColumn(t *testing.T) {
 	})
 }
 
+func TestColumnTypes(t *testing.T) {
+	typesJSON := test.TypesJSONConfFile(t)
+
+	byteValue := []byte(typesJSON)
+	tableStruct, err := sqlsol.NewParser(byteValue)
+	require.NoError(t, err)
+
+	t.Run("successfully maps event input types to lossless SQL column types", func(t *testing.T) {
+		expected := map[string]struct {
+			sqlType types.SQLColumnType
+			length  int
+		}{
+			"small":   {types.SQLColumnTypeInt, 0},
+			"tags":    {types.SQLColumnTypeVarchar, 64},
+			"amount":  {types.SQLColumnTypeNumeric, 78},
+			"balance": {types.SQLColumnTypeBigInt, 0},
+			"id":      {types.SQLColumnTypeVarchar, 64},
+			"values":  {types.SQLColumnTypeJSON, 0},
+			"names":   {types.SQLColumnTypeJSON, 0},
+			"owner":   {types.SQLColumnTypeJSON, 0},
+		}
+
+		for input, exp := range expected {
+			column, err := tableStruct.GetColumn("UpdateTypes", input)
+			require.NoError(t, err)
+			require.Equal(t, exp.sqlType, column.Type, input)
+			require.Equal(t, exp.length, column.Length, input)
+		}
+	})
+
+	t.Run("successfully computes the signature hash of events with tuple inputs", func(t *testing.T) {
+		eventSpec, err := tableStruct.GetEventSpec("UpdateTypes")
+		require.NoError(t, err)
+
+		signature := "UpdateTypes(int8,string,uint256,int64,bytes32,uint16[2],string[],(address,bool,bytes))"
+		require.Equal(t, signature, eventSpec.Event.Signature())
+		require.Equal(t, binary.LeftPadWord256(sha3.Sha3([]byte(signature))), eventSpec.ID)
+	})
+
+	t.Run("returns an error if a sized type is not valid", func(t *testing.T) {
+		for _, inputType := range []string{"uint7", "int264", "bytes33", "bytes0", "uint[0]", "tuple"} {
+			badJSON := strings.Replace(typesJSON, `"type": "uint256"`, `"type": "`+inputType+`"`, 1)
+
+			_, err := sqlsol.NewParser([]byte(badJSON))
+			require.Error(t, err, inputType)
+		}
+	})
+}
+
+func TestGetEventSpec(t *testing.T) {
+	goodJSON := test.GoodJSONConfFile(t)
+
+	byteValue := []byte(goodJSON)
+	tableStruct, _ := sqlsol.NewParser(byteValue)
+
+	t.Run("successfully gets the event specification for a given event name", func(t *testing.T) {
+		eventSpec, err := tableStruct.GetEventSpec("TEST_EVENTS")
+		require.NoError(t, err)
+		require.Equal(t, "TEST_EVENTS", eventSpec.Name)
+		require.Equal(t, false, eventSpec.Event.Anonymous)
+		require.Equal(t, 3, len(eventSpec.Event.Inputs))
+		require.Equal(t, "description", eventSpec.Event.Inputs[1].Name)
+		require.Equal(t, "string", eventSpec.Event.Inputs[1].Type)
+	})
+
+	t.Run("unsuccessfully gets the event specification for a non existent event name", func(t *testing.T) {
+		_, err := tableStruct.GetEventSpec("NOT_EXISTS")
+		require.Error(t, err)
+	})
+}
+
 func TestSetTableName(t *testing.T) {
 	goodJSON := test.GoodJSONConfFile(t)
 
@@ -170,3 +311,28 @@ func TestGetTables(t *testing.T) {
 		require.Equal(t, 2, len(tables))
 	})
 }
+
+func TestAddBlockTimeColumn(t *testing.T) {
+	t.Run("successfully adds the block time column to every event table", func(t *testing.T) {
+		tableStruct, err := sqlsol.NewParser([]byte(test.GoodJSONConfFile(t)))
+		require.NoError(t, err)
+
+		require.NoError(t, tableStruct.AddBlockTimeColumn())
+
+		for _, table := range tableStruct.GetTables() {
+			column, ok := table.Columns[sqlsol.BlockTimeKey]
+			require.Equal(t, true, ok)
+			require.Equal(t, "blocktime", column.Name)
+			require.Equal(t, types.SQLColumnTypeTimeStamp, column.Type)
+			require.Equal(t, len(table.Columns), column.Order)
+		}
+	})
+
+	t.Run("returns an error if an event table already has a block time column", func(t *testing.T) {
+		tableStruct, err := sqlsol.NewParser([]byte(test.GoodJSONConfFile(t)))
+		require.NoError(t, err)
+
+		require.NoError(t, tableStruct.AddBlockTimeColumn())
+		require.Error(t, tableStruct.AddBlockTimeColumn())
+	})
+}
@@ -0,0 +1,386 @@
+package sqlsol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/monax/bosmarmot/vent/types"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+// system events (non log execution events) which can be stored in system tables
+const (
+	SystemEventCall          = "call"
+	SystemEventInput         = "input"
+	SystemEventOutput        = "output"
+	SystemEventGovernAccount = "governaccount"
+	SystemEventTx            = "tx"
+	SystemEventBlock         = "block"
+)
+
+// system tables names
+const (
+	SystemTableCalls          = "_calls"
+	SystemTableInputs         = "_inputs"
+	SystemTableOutputs        = "_outputs"
+	SystemTableGovernAccounts = "_governaccounts"
+	SystemTableTransactions   = "_transactions"
+	SystemTableBlocks         = "_blocks"
+)
+
+// systemEventTypes maps system events to execution event types
+var systemEventTypes = map[string]exec.EventType{
+	SystemEventCall:          exec.TypeCall,
+	SystemEventInput:         exec.TypeAccountInput,
+	SystemEventOutput:        exec.TypeAccountOutput,
+	SystemEventGovernAccount: exec.TypeGovernAccount,
+	SystemEventTx:            exec.TypeTxExecution,
+	SystemEventBlock:         exec.TypeBlockExecution,
+}
+
+// systemEventTables maps system events to system tables names
+var systemEventTables = map[string]string{
+	SystemEventCall:          SystemTableCalls,
+	SystemEventInput:         SystemTableInputs,
+	SystemEventOutput:        SystemTableOutputs,
+	SystemEventGovernAccount: SystemTableGovernAccounts,
+	SystemEventTx:            SystemTableTransactions,
+	SystemEventBlock:         SystemTableBlocks,
+}
+
+// SystemTables contains the system tables of non log execution events
+type SystemTables struct {
+	// maps execution event types to system tables names
+	EventTables map[exec.EventType]string
+	// maps system tables names to table structures
+	Tables types.EventTables
+}
+
+// NewSystemTables returns the system tables of the given system events
+// (call, input, output, governaccount, tx, block)
+func NewSystemTables(systemEvents []string) (*SystemTables, error) {
+	systemTables := &SystemTables{
+		EventTables: make(map[exec.EventType]string),
+		Tables:      make(types.EventTables),
+	}
+
+	for _, systemEvent := range systemEvents {
+		systemEvent = strings.ToLower(strings.TrimSpace(systemEvent))
+
+		eventType, ok := systemEventTypes[systemEvent]
+		if !ok {
+			return nil, fmt.Errorf("NewSystemTables: unknown system event %s", systemEvent)
+		}
+
+		tableName := systemEventTables[systemEvent]
+
+		systemTables.EventTables[eventType] = tableName
+		systemTables.Tables[tableName] = types.SQLTable{
+			Name:    tableName,
+			Columns: getSystemColumns(eventType),
+		}
+	}
+
+	return systemTables, nil
+}
+
+// GetTables returns the system tables structures
+func (s *SystemTables) GetTables() types.EventTables {
+	return s.Tables
+}
+
+// StoresEvents returns true if any non log execution event is stored
+func (s *SystemTables) StoresEvents() bool {
+	for eventType := range s.EventTables {
+		if eventType != exec.TypeTxExecution && eventType != exec.TypeBlockExecution {
+			return true
+		}
+	}
+	return false
+}
+
+// StoresTxs returns true if transactions are stored
+func (s *SystemTables) StoresTxs() bool {
+	_, ok := s.EventTables[exec.TypeTxExecution]
+	return ok
+}
+
+// GetEventRow returns the system table name and row of a non log execution event,
+// ok is false if events of its type are not stored
+func (s *SystemTables) GetEventRow(ev *exec.Event) (tableName string, row types.EventDataRow, ok bool, err error) {
+	header := ev.GetHeader()
+
+	if tableName, ok = s.EventTables[header.GetEventType()]; !ok {
+		return "", nil, false, nil
+	}
+
+	// every system table relates rows with source transactions
+	row = types.EventDataRow{
+		"height":    fmt.Sprintf("%v", header.GetHeight()),
+		"txhash":    string(header.TxHash),
+		"index":     fmt.Sprintf("%v", header.GetIndex()),
+		"eventtype": header.GetEventType().String(),
+	}
+
+	switch {
+	case ev.Call != nil:
+		if callData := ev.Call.GetCallData(); callData != nil {
+			row["caller"] = callData.Caller.String()
+			row["callee"] = callData.Callee.String()
+			row["calldata"] = callData.Data.String()
+			row["value"] = fmt.Sprintf("%v", callData.Value)
+			row["gas"] = fmt.Sprintf("%v", callData.Gas)
+		}
+		row["origin"] = ev.Call.Origin.String()
+		row["stackdepth"] = fmt.Sprintf("%v", ev.Call.StackDepth)
+		row["returndata"] = ev.Call.Return.String()
+
+	case ev.Input != nil:
+		row["address"] = ev.Input.Address.String()
+
+	case ev.Output != nil:
+		row["address"] = ev.Output.Address.String()
+
+	case ev.GovernAccount != nil:
+		if err = getGovernAccountRow(ev.GovernAccount, row); err != nil {
+			return "", nil, false, err
+		}
+
+	default:
+		return "", nil, false, fmt.Errorf("GetEventRow: %s event without payload", header.GetEventType())
+	}
+
+	return tableName, row, true, nil
+}
+
+// StoresBlocks returns true if blocks are stored
+func (s *SystemTables) StoresBlocks() bool {
+	_, ok := s.EventTables[exec.TypeBlockExecution]
+	return ok
+}
+
+// GetBlockRow returns the blocks table row of a block
+func (s *SystemTables) GetBlockRow(height uint64, blockHeader *exec.BlockHeader) (types.EventDataRow, error) {
+	row := types.EventDataRow{
+		"height": fmt.Sprintf("%v", height),
+	}
+
+	header, err := DecodeBlockHeader(blockHeader)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockRow: %v", err)
+	}
+
+	if header != nil {
+		row["chainid"] = header.ChainID
+		row["blocktime"] = FormatBlockTime(header.Time)
+		row["numtxs"] = fmt.Sprintf("%v", header.NumTxs)
+		row["totaltxs"] = fmt.Sprintf("%v", header.TotalTxs)
+		row["lastblockhash"] = binary.HexBytes(header.LastBlockHash).String()
+		row["validatorshash"] = binary.HexBytes(header.ValidatorsHash).String()
+		row["apphash"] = binary.HexBytes(header.AppHash).String()
+		row["proposer"] = binary.HexBytes(header.Proposer.Address).String()
+	}
+
+	return row, nil
+}
+
+// DecodeBlockHeader decodes the tendermint header of a block (nil if not given)
+func DecodeBlockHeader(blockHeader *exec.BlockHeader) (*abciTypes.Header, error) {
+	if blockHeader == nil || blockHeader.JSON == "" {
+		return nil, nil
+	}
+
+	header := new(abciTypes.Header)
+	if err := json.Unmarshal([]byte(blockHeader.JSON), header); err != nil {
+		return nil, fmt.Errorf("can not decode block header: %v", err)
+	}
+
+	return header, nil
+}
+
+// FormatBlockTime formats block times as SQL timestamps (in UTC)
+func FormatBlockTime(blockTime time.Time) string {
+	return blockTime.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// GetTxRow returns the transactions table row of a transaction execution
+func (s *SystemTables) GetTxRow(txe *exec.TxExecution) (types.EventDataRow, error) {
+	row := types.EventDataRow{
+		"height": fmt.Sprintf("%v", txe.GetHeight()),
+		"txhash": string(txe.TxHash),
+		"index":  fmt.Sprintf("%v", txe.GetIndex()),
+		"txtype": txe.GetTxType().String(),
+	}
+
+	// signer addresses are taken from public keys if not given
+	signers := []string{}
+	if txe.Envelope != nil {
+		for _, signatory := range txe.Envelope.Signatories {
+			switch {
+			case signatory.Address != nil:
+				signers = append(signers, signatory.Address.String())
+			case signatory.PublicKey != nil:
+				signers = append(signers, signatory.PublicKey.Address().String())
+			}
+		}
+	}
+
+	bytes, err := json.Marshal(signers)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxRow: can not encode signers: %v", err)
+	}
+	row["signers"] = string(bytes)
+
+	if result := txe.GetResult(); result != nil {
+		row["gasused"] = fmt.Sprintf("%v", result.GetGasUsed())
+		row["result"] = binary.HexBytes(result.GetReturn()).String()
+	}
+
+	if exception := txe.GetException(); exception != nil {
+		row["exceptioncode"] = fmt.Sprintf("%v", uint32(exception.ErrorCode()))
+		row["exception"] = exception.Error()
+	}
+
+	return row, nil
+}
+
+// getGovernAccountRow maps the account update of a govern account event to a system table row
+func getGovernAccountRow(governAccount *exec.GovernAccountEvent, row types.EventDataRow) error {
+	account := governAccount.GetAccountUpdate()
+	if account == nil {
+		return fmt.Errorf("getGovernAccountRow: govern account event without account update")
+	}
+
+	row["name"] = account.Name
+
+	if account.Address != nil {
+		row["address"] = account.Address.String()
+	}
+	if account.NodeAddress != nil {
+		row["nodeaddress"] = account.NodeAddress.String()
+	}
+	if account.PublicKey != nil {
+		row["publickey"] = account.PublicKey.String()
+	}
+	if account.Code != nil {
+		row["code"] = account.Code.String()
+	}
+
+	// amounts, permissions and roles are stored as JSON arrays
+	amounts := make([]map[string]string, len(account.Amounts))
+	for i, amount := range account.Amounts {
+		amounts[i] = map[string]string{
+			"type":   amount.Type.String(),
+			"amount": fmt.Sprintf("%v", amount.Amount),
+		}
+	}
+
+	for column, value := range map[string]interface{}{
+		"amounts":     amounts,
+		"permissions": nonNilStrings(account.Permissions),
+		"roles":       nonNilStrings(account.Roles),
+	} {
+		bytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		row[column] = string(bytes)
+	}
+
+	return nil
+}
+
+// nonNilStrings returns an empty slice instead of nil (encoded as a JSON array instead of null)
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// getSystemColumns returns the columns of the system table of an execution event type,
+// keyed by column names
+func getSystemColumns(eventType exec.EventType) map[string]types.SQLTableColumn {
+	if eventType == exec.TypeBlockExecution {
+		return getTableColumns([]types.SQLTableColumn{
+			{Name: "height", Type: types.SQLColumnTypeVarchar, Length: 100, Primary: true},
+			{Name: "chainid", Type: types.SQLColumnTypeVarchar, Length: 100},
+			{Name: "blocktime", Type: types.SQLColumnTypeTimeStamp},
+			{Name: "numtxs", Type: types.SQLColumnTypeInt},
+			{Name: "totaltxs", Type: types.SQLColumnTypeBigInt},
+			{Name: "lastblockhash", Type: types.SQLColumnTypeVarchar, Length: 64},
+			{Name: "validatorshash", Type: types.SQLColumnTypeVarchar, Length: 64},
+			{Name: "apphash", Type: types.SQLColumnTypeVarchar, Length: 64},
+			{Name: "proposer", Type: types.SQLColumnTypeVarchar, Length: 100},
+		})
+	}
+
+	if eventType == exec.TypeTxExecution {
+		return getTableColumns([]types.SQLTableColumn{
+			{Name: "height", Type: types.SQLColumnTypeVarchar, Length: 100},
+			{Name: "txhash", Type: types.SQLColumnTypeByteA, Primary: true},
+			{Name: "index", Type: types.SQLColumnTypeInt},
+			{Name: "txtype", Type: types.SQLColumnTypeVarchar, Length: 100},
+			{Name: "signers", Type: types.SQLColumnTypeJSON},
+			{Name: "gasused", Type: types.SQLColumnTypeNumeric, Length: 20},
+			{Name: "result", Type: types.SQLColumnTypeText},
+			{Name: "exceptioncode", Type: types.SQLColumnTypeBigInt},
+			{Name: "exception", Type: types.SQLColumnTypeText},
+		})
+	}
+
+	columns := []types.SQLTableColumn{
+		{Name: "height", Type: types.SQLColumnTypeVarchar, Length: 100},
+		{Name: "txhash", Type: types.SQLColumnTypeByteA, Primary: true},
+		{Name: "index", Type: types.SQLColumnTypeInt, Primary: true},
+		{Name: "eventtype", Type: types.SQLColumnTypeVarchar, Length: 100},
+	}
+
+	switch eventType {
+	case exec.TypeCall:
+		columns = append(columns,
+			types.SQLTableColumn{Name: "caller", Type: types.SQLColumnTypeVarchar, Length: 100},
+			types.SQLTableColumn{Name: "callee", Type: types.SQLColumnTypeVarchar, Length: 100},
+			types.SQLTableColumn{Name: "calldata", Type: types.SQLColumnTypeText},
+			types.SQLTableColumn{Name: "value", Type: types.SQLColumnTypeNumeric, Length: 20},
+			types.SQLTableColumn{Name: "gas", Type: types.SQLColumnTypeNumeric, Length: 20},
+			types.SQLTableColumn{Name: "origin", Type: types.SQLColumnTypeVarchar, Length: 100},
+			types.SQLTableColumn{Name: "stackdepth", Type: types.SQLColumnTypeNumeric, Length: 20},
+			types.SQLTableColumn{Name: "returndata", Type: types.SQLColumnTypeText},
+		)
+
+	case exec.TypeAccountInput, exec.TypeAccountOutput:
+		columns = append(columns,
+			types.SQLTableColumn{Name: "address", Type: types.SQLColumnTypeVarchar, Length: 100},
+		)
+
+	case exec.TypeGovernAccount:
+		columns = append(columns,
+			types.SQLTableColumn{Name: "name", Type: types.SQLColumnTypeText},
+			types.SQLTableColumn{Name: "address", Type: types.SQLColumnTypeVarchar, Length: 100},
+			types.SQLTableColumn{Name: "nodeaddress", Type: types.SQLColumnTypeVarchar, Length: 100},
+			types.SQLTableColumn{Name: "publickey", Type: types.SQLColumnTypeText},
+			types.SQLTableColumn{Name: "amounts", Type: types.SQLColumnTypeJSON},
+			types.SQLTableColumn{Name: "permissions", Type: types.SQLColumnTypeJSON},
+			types.SQLTableColumn{Name: "roles", Type: types.SQLColumnTypeJSON},
+			types.SQLTableColumn{Name: "code", Type: types.SQLColumnTypeText},
+		)
+	}
+
+	return getTableColumns(columns)
+}
+
+// getTableColumns keys table columns by column names and sets their order
+func getTableColumns(columns []types.SQLTableColumn) map[string]types.SQLTableColumn {
+	tableColumns := make(map[string]types.SQLTableColumn, len(columns))
+	for i, column := range columns {
+		column.Order = i + 1
+		tableColumns[column.Name] = column
+	}
+
+	return tableColumns
+}
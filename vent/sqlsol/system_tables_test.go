@@ -0,0 +1,173 @@
+package sqlsol_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/burrow/crypto"
+	"github.com/hyperledger/burrow/execution/errors"
+	"github.com/hyperledger/burrow/execution/exec"
+	"github.com/hyperledger/burrow/genesis/spec"
+	"github.com/hyperledger/burrow/txs"
+	"github.com/hyperledger/burrow/txs/payload"
+	"github.com/monax/bosmarmot/vent/sqlsol"
+	"github.com/monax/bosmarmot/vent/types"
+	"github.com/stretchr/testify/require"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestSystemTables(t *testing.T) {
+	address := crypto.Address{1, 2, 3}
+
+	t.Run("returns an error if a system event is unknown", func(t *testing.T) {
+		_, err := sqlsol.NewSystemTables([]string{"call", "unknown"})
+		require.Error(t, err)
+	})
+
+	t.Run("successfully builds system tables structures", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"call", "input", "output", "governaccount", "tx"})
+		require.NoError(t, err)
+		require.Equal(t, true, systemTables.StoresEvents())
+		require.Equal(t, true, systemTables.StoresTxs())
+
+		tables := systemTables.GetTables()
+		require.Equal(t, 5, len(tables))
+		require.Equal(t, sqlsol.SystemTableCalls, tables[sqlsol.SystemTableCalls].Name)
+		require.Equal(t, true, tables[sqlsol.SystemTableCalls].Columns["txhash"].Primary)
+		require.Equal(t, true, tables[sqlsol.SystemTableCalls].Columns["index"].Primary)
+		require.Equal(t, types.SQLColumnTypeNumeric, tables[sqlsol.SystemTableCalls].Columns["value"].Type)
+		require.Equal(t, types.SQLColumnTypeJSON, tables[sqlsol.SystemTableGovernAccounts].Columns["permissions"].Type)
+	})
+
+	t.Run("successfully maps call events to system table rows", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"call"})
+		require.NoError(t, err)
+
+		ev := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeCall, Height: 5, Index: 2, TxHash: []byte("hash")},
+			Call: &exec.CallEvent{
+				CallData:   &exec.CallData{Caller: address, Callee: address, Data: []byte{0xAB}, Value: 10, Gas: 20},
+				Origin:     address,
+				StackDepth: 1,
+				Return:     []byte{0xCD},
+			},
+		}
+
+		tableName, row, ok, err := systemTables.GetEventRow(ev)
+		require.NoError(t, err)
+		require.Equal(t, true, ok)
+		require.Equal(t, sqlsol.SystemTableCalls, tableName)
+		require.Equal(t, "5", row["height"])
+		require.Equal(t, "2", row["index"])
+		require.Equal(t, "hash", row["txhash"])
+		require.Equal(t, "CallEvent", row["eventtype"])
+		require.Equal(t, address.String(), row["caller"])
+		require.Equal(t, "AB", row["calldata"])
+		require.Equal(t, "10", row["value"])
+		require.Equal(t, "20", row["gas"])
+		require.Equal(t, "1", row["stackdepth"])
+		require.Equal(t, "CD", row["returndata"])
+	})
+
+	t.Run("successfully maps govern account events to system table rows", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"governaccount"})
+		require.NoError(t, err)
+
+		ev := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeGovernAccount, Height: 5},
+			GovernAccount: &exec.GovernAccountEvent{
+				AccountUpdate: &spec.TemplateAccount{Name: "validator", Address: &address, Roles: []string{"admin"}},
+			},
+		}
+
+		tableName, row, ok, err := systemTables.GetEventRow(ev)
+		require.NoError(t, err)
+		require.Equal(t, true, ok)
+		require.Equal(t, sqlsol.SystemTableGovernAccounts, tableName)
+		require.Equal(t, "validator", row["name"])
+		require.Equal(t, address.String(), row["address"])
+		require.Equal(t, `["admin"]`, row["roles"])
+		require.Equal(t, `[]`, row["permissions"])
+		require.Equal(t, `[]`, row["amounts"])
+	})
+
+	t.Run("successfully maps transactions to transactions table rows", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"tx"})
+		require.NoError(t, err)
+		require.Equal(t, false, systemTables.StoresEvents())
+		require.Equal(t, true, systemTables.StoresTxs())
+
+		txe := &exec.TxExecution{
+			TxType:   payload.TypeCall,
+			TxHash:   []byte("hash"),
+			Height:   5,
+			Index:    1,
+			Envelope: &txs.Envelope{Signatories: []txs.Signatory{{Address: &address}}},
+			Result:   &exec.Result{Return: []byte{0xAB}, GasUsed: 30},
+		}
+		txe.SetException(errors.ErrorCodeInsufficientGas)
+
+		row, err := systemTables.GetTxRow(txe)
+		require.NoError(t, err)
+		require.Equal(t, "5", row["height"])
+		require.Equal(t, "hash", row["txhash"])
+		require.Equal(t, "1", row["index"])
+		require.Equal(t, "CallTx", row["txtype"])
+		require.Equal(t, `["`+address.String()+`"]`, row["signers"])
+		require.Equal(t, "30", row["gasused"])
+		require.Equal(t, "AB", row["result"])
+		require.NotEqual(t, "", row["exception"])
+	})
+
+	t.Run("successfully maps block headers to blocks table rows", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"block"})
+		require.NoError(t, err)
+		require.Equal(t, false, systemTables.StoresEvents())
+		require.Equal(t, true, systemTables.StoresBlocks())
+
+		blockTime := time.Date(2018, 10, 2, 15, 4, 5, 123456000, time.FixedZone("CEST", 2*60*60))
+		headerJSON, err := json.Marshal(abciTypes.Header{
+			ChainID:  "test-chain",
+			Height:   7,
+			Time:     blockTime,
+			NumTxs:   2,
+			TotalTxs: 10,
+			AppHash:  []byte{0xAB, 0xCD},
+			Proposer: abciTypes.Validator{Address: address.Bytes()},
+		})
+		require.NoError(t, err)
+
+		row, err := systemTables.GetBlockRow(7, &exec.BlockHeader{JSON: string(headerJSON), NumTxs: 2})
+		require.NoError(t, err)
+		require.Equal(t, "7", row["height"])
+		require.Equal(t, "test-chain", row["chainid"])
+		require.Equal(t, "2018-10-02 13:04:05.123456", row["blocktime"])
+		require.Equal(t, "2", row["numtxs"])
+		require.Equal(t, "10", row["totaltxs"])
+		require.Equal(t, "ABCD", row["apphash"])
+		require.Equal(t, address.String(), row["proposer"])
+	})
+
+	t.Run("returns an error if a block header is malformed", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"block"})
+		require.NoError(t, err)
+
+		_, err = systemTables.GetBlockRow(7, &exec.BlockHeader{JSON: "{"})
+		require.Error(t, err)
+	})
+
+	t.Run("skips events not stored in system tables", func(t *testing.T) {
+		systemTables, err := sqlsol.NewSystemTables([]string{"call"})
+		require.NoError(t, err)
+
+		ev := &exec.Event{
+			Header: &exec.Header{EventType: exec.TypeAccountInput},
+			Input:  &exec.InputEvent{Address: address},
+		}
+
+		_, _, ok, err := systemTables.GetEventRow(ev)
+		require.NoError(t, err)
+		require.Equal(t, false, ok)
+	})
+}
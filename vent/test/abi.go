@@ -0,0 +1,86 @@
+package test
+
+import (
+	"testing"
+)
+
+// ABIJSONFile sets a contract ABI (as found in solc and burrow deploy outputs)
+// to be used in generator tests
+func ABIJSONFile(t *testing.T) string {
+	t.Helper()
+
+	abiJSONFile := `[
+		{
+			"constant": true,
+			"inputs": [],
+			"name": "getNumberOfEvents",
+			"outputs": [{"name": "size", "type": "uint256"}],
+			"payable": false,
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"anonymous": false,
+			"inputs": [{
+				"indexed": true,
+				"name": "name",
+				"type": "bytes32"
+			}, {
+				"indexed": true,
+				"name": "key",
+				"type": "bytes32"
+			}, {
+				"indexed": true,
+				"name": "description",
+				"type": "bytes32"
+			}],
+			"name": "UpdateTestEvents",
+			"type": "event"
+		},
+		{
+			"anonymous": false,
+			"inputs": [{
+				"indexed": false,
+				"name": "userName",
+				"type": "string"
+			}, {
+				"indexed": true,
+				"name": "userAddress",
+				"type": "address"
+			}, {
+				"indexed": false,
+				"name": "index",
+				"type": "uint256"
+			}, {
+				"indexed": false,
+				"name": "",
+				"type": "uint256[]"
+			}, {
+				"indexed": false,
+				"name": "owner",
+				"type": "tuple",
+				"components": [{
+					"name": "account",
+					"type": "address"
+				}, {
+					"name": "active",
+					"type": "bool"
+				}]
+			}, {
+				"indexed": false,
+				"name": "to",
+				"type": "address"
+			}],
+			"name": "UpdateUserAccount",
+			"type": "event"
+		},
+		{
+			"anonymous": false,
+			"inputs": [],
+			"name": "Ping",
+			"type": "event"
+		}
+	]`
+
+	return abiJSONFile
+}
@@ -28,7 +28,7 @@ func randString(n int) string {
 }
 
 // NewTestDB creates a database connection for testing
-func NewTestDB(t *testing.T) (*sqldb.SQLDB, func()) {
+func NewTestDB(t testing.TB) (*sqldb.SQLDB, func()) {
 	t.Helper()
 
 	cfg := config.DefaultFlags()
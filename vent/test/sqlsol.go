@@ -1,6 +1,7 @@
 package test
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -11,7 +12,7 @@ func GoodJSONConfFile(t *testing.T) string {
 	goodJSONConfFile := `[
 		{
 			"TableName" : "UserAccounts",
-			"Filter" : "LOG0 = 'UserAccounts'",
+			"Filter" : "LOG1 = 'UserAccounts'",
 			"Event"  : {
 				"anonymous": false,
 				"inputs": [{
@@ -37,7 +38,8 @@ func GoodJSONConfFile(t *testing.T) string {
 		},
 		{
 			"TableName" : "EventTest",
-			"Filter" : "LOG0 = 'EventTest'",
+			"Filter" : "LOG1 = 'TEST_EVENTS'",
+			"EventNameTopic" : true,
 			"Event"  : {
 				"anonymous": false,
 				"inputs": [{
@@ -66,6 +68,519 @@ func GoodJSONConfFile(t *testing.T) string {
 	return goodJSONConfFile
 }
 
+// IndexedInputsJSONConfFile sets a json file with indexed event inputs to be used in decoder tests
+func IndexedInputsJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	indexedInputsJSONConfFile := `[
+		{
+			"TableName" : "EventTest",
+			"Filter" : "LOG1 = 'TEST_EVENTS'",
+			"EventNameTopic" : true,
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "name",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "key",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "description",
+					"type": "bytes32"
+				}, {
+					"indexed": false,
+					"name": "UnimportantInfo",
+					"type": "uint"
+				}],
+				"name": "TEST_EVENTS",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "testname", "primary" : true},
+				"description": {"name" : "testdescription", "primary" : false}
+			}
+		}
+	]`
+
+	return indexedInputsJSONConfFile
+}
+
+// FilterJSONConfFile sets a json file with event filters to be used in filter tests
+func FilterJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	filterJSONConfFile := `[
+		{
+			"TableName" : "UserAccounts",
+			"Filter" : "Address = '4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51' AND EventType = 'LogEvent'",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": false,
+					"name": "userName",
+					"type": "string"
+				}, {
+					"indexed": false,
+					"name": "userAddress",
+					"type": "address"
+				}],
+				"name": "UpdateUserAccount",
+				"type": "event"
+			},
+			"Columns"  : {
+				"userAddress" : {"name" : "address", "primary" : true},
+				"userName": {"name" : "username", "primary" : false}
+			}
+		},
+		{
+			"TableName" : "EventTest",
+			"Filter" : "LOG1 = 'TEST_EVENTS' AND Address = '4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51'",
+			"EventNameTopic" : true,
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "name",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "key",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "description",
+					"type": "bytes32"
+				}],
+				"name": "TEST_EVENTS",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "testname", "primary" : true},
+				"description": {"name" : "testdescription", "primary" : false}
+			}
+		}
+	]`
+
+	return filterJSONConfFile
+}
+
+// BadFilterJSONConfFile sets a json file with a malformed event filter to be used in filter tests
+func BadFilterJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	badFilterJSONConfFile := `[
+		{
+			"TableName" : "EventTest",
+			"Filter" : "LOG1 == TEST_EVENTS",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "name",
+					"type": "bytes32"
+				}],
+				"name": "TEST_EVENTS",
+				"type": "event"
+			},
+			"Columns"  : {
+				"name" : {"name" : "testname", "primary" : true}
+			}
+		}
+	]`
+
+	return badFilterJSONConfFile
+}
+
+// AddressesJSONConfFile sets a json file with an event mapped to different tables
+// depending on contract addresses to be used in parser tests
+func AddressesJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	addressesJSONConfFile := `[
+		{
+			"TableName" : "UserAccounts",
+			"Addresses" : ["4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51"],
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": false,
+					"name": "userName",
+					"type": "string"
+				}, {
+					"indexed": false,
+					"name": "userAddress",
+					"type": "address"
+				}, {
+					"indexed": false,
+					"name": "UnimportantInfo",
+					"type": "uint"
+				}],
+				"name": "UpdateUserAccount",
+				"type": "event"
+			},
+			"Columns"  : {
+				"userAddress" : {"name" : "address", "primary" : true},
+				"userName": {"name" : "username", "primary" : false}
+			}
+		},
+		{
+			"TableName" : "OtherAccounts",
+			"Addresses" : ["1DE01D52E5EA6A5D2F5B3D514AE393495334CDAF", "5B3D514AE393495334CDAF1DE01D52E5EA6A5D2F"],
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": false,
+					"name": "userName",
+					"type": "string"
+				}, {
+					"indexed": false,
+					"name": "userAddress",
+					"type": "address"
+				}, {
+					"indexed": false,
+					"name": "UnimportantInfo",
+					"type": "uint"
+				}],
+				"name": "UpdateUserAccount",
+				"type": "event"
+			},
+			"Columns"  : {
+				"userAddress" : {"name" : "address", "primary" : true},
+				"userName": {"name" : "username", "primary" : false}
+			}
+		}
+	]`
+
+	return addressesJSONConfFile
+}
+
+// DeployFileJSONConfFile sets a json file with contract addresses read from
+// the given burrow deploy output file to be used in parser tests
+func DeployFileJSONConfFile(t *testing.T, deployFile string) string {
+	t.Helper()
+
+	deployFileJSONConfFile := `[
+		{
+			"TableName" : "EventTest",
+			"Addresses" : ["$deployEventsTest"],
+			"DeployFile" : "%s",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "name",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "key",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "description",
+					"type": "bytes32"
+				}],
+				"name": "UpdateTestEvents",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "testname", "primary" : true},
+				"description": {"name" : "testdescription", "primary" : false}
+			}
+		}
+	]`
+
+	return fmt.Sprintf(deployFileJSONConfFile, deployFile)
+}
+
+// TypesJSONConfFile sets a json file with an event using sized, array and tuple input types
+// to be used in parser and decoder tests
+func TypesJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	typesJSONConfFile := `[
+		{
+			"TableName" : "Types",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "small",
+					"type": "int8"
+				}, {
+					"indexed": true,
+					"name": "tags",
+					"type": "string"
+				}, {
+					"indexed": false,
+					"name": "amount",
+					"type": "uint256"
+				}, {
+					"indexed": false,
+					"name": "balance",
+					"type": "int64"
+				}, {
+					"indexed": false,
+					"name": "id",
+					"type": "bytes32"
+				}, {
+					"indexed": false,
+					"name": "values",
+					"type": "uint16[2]"
+				}, {
+					"indexed": false,
+					"name": "names",
+					"type": "string[]"
+				}, {
+					"indexed": false,
+					"name": "owner",
+					"type": "tuple",
+					"components": [{
+						"name": "account",
+						"type": "address"
+					}, {
+						"name": "active",
+						"type": "bool"
+					}, {
+						"name": "data",
+						"type": "bytes"
+					}]
+				}],
+				"name": "UpdateTypes",
+				"type": "event"
+			},
+			"Columns"  : {
+				"small" : {"name" : "small", "primary" : false},
+				"tags" : {"name" : "tags", "primary" : false},
+				"amount" : {"name" : "amount", "primary" : false},
+				"balance" : {"name" : "balance", "primary" : false},
+				"id" : {"name" : "id", "primary" : true},
+				"values" : {"name" : "numbers", "primary" : false},
+				"names" : {"name" : "names", "primary" : false},
+				"owner" : {"name" : "owner", "primary" : false}
+			}
+		}
+	]`
+
+	return typesJSONConfFile
+}
+
+// NestedTypesJSONConfFile sets a json file with a dynamic array nested in a tuple
+// to be used in event decoding tests
+func NestedTypesJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	nestedTypesJSONConfFile := `[
+		{
+			"TableName" : "NestedTypes",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": false,
+					"name": "group",
+					"type": "tuple",
+					"components": [{
+						"name": "id",
+						"type": "uint256"
+					}, {
+						"name": "names",
+						"type": "string[]"
+					}]
+				}, {
+					"indexed": false,
+					"name": "payload",
+					"type": "bytes"
+				}],
+				"name": "UpdateNestedTypes",
+				"type": "event"
+			},
+			"Columns"  : {
+				"group" : {"name" : "grp", "primary" : false},
+				"payload" : {"name" : "payload", "primary" : true}
+			}
+		}
+	]`
+
+	return nestedTypesJSONConfFile
+}
+
+// ChildTablesJSONConfFile sets a json file with array inputs expanded into child tables
+// to be used in parser tests
+func ChildTablesJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	childTablesJSONConfFile := `[
+		{
+			"TableName" : "Groups",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "groupId",
+					"type": "bytes32"
+				}, {
+					"indexed": false,
+					"name": "members",
+					"type": "address[]"
+				}, {
+					"indexed": false,
+					"name": "shares",
+					"type": "uint[2]"
+				}, {
+					"indexed": false,
+					"name": "roles",
+					"type": "uint8[]"
+				}],
+				"name": "UpdateGroup",
+				"type": "event"
+			},
+			"Columns"  : {
+				"groupId" : {"name" : "groupid", "primary" : true},
+				"members" : {"name" : "member", "primary" : false, "childTable" : true},
+				"shares" : {"name" : "share", "primary" : false, "childTable" : true},
+				"roles" : {"name" : "roles", "primary" : false}
+			}
+		}
+	]`
+
+	return childTablesJSONConfFile
+}
+
+// AnonymousJSONConfFile sets a json file with an anonymous event
+// to be used in parser and decoder tests
+func AnonymousJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	anonymousJSONConfFile := `[
+		{
+			"TableName" : "Transfers",
+			"Addresses" : ["4AE393495334CDAF1DE01D52E5EA6A5D2F5B3D51"],
+			"Event"  : {
+				"anonymous": true,
+				"inputs": [{
+					"indexed": true,
+					"name": "from",
+					"type": "address"
+				}, {
+					"indexed": true,
+					"name": "to",
+					"type": "address"
+				}, {
+					"indexed": false,
+					"name": "value",
+					"type": "uint256"
+				}],
+				"name": "Transfer",
+				"type": "event"
+			},
+			"Columns"  : {
+				"from" : {"name" : "sender", "primary" : true},
+				"to" : {"name" : "receiver", "primary" : true},
+				"value" : {"name" : "amount", "primary" : false}
+			}
+		}
+	]`
+
+	return anonymousJSONConfFile
+}
+
+// DerivedColumnsJSONConfFile sets a json file with columns derived from event inputs
+// to be used in parser tests
+func DerivedColumnsJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	derivedColumnsJSONConfFile := `[
+		{
+			"TableName" : "Tokens",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{
+					"indexed": true,
+					"name": "symbol",
+					"type": "bytes32"
+				}, {
+					"indexed": true,
+					"name": "owner",
+					"type": "address"
+				}, {
+					"indexed": false,
+					"name": "amount",
+					"type": "uint256"
+				}, {
+					"indexed": false,
+					"name": "status",
+					"type": "uint8"
+				}],
+				"name": "UpdateToken",
+				"type": "event"
+			},
+			"Columns"  : {
+				"symbol" : {"name" : "symbolhex", "primary" : false},
+				"symbolText" : {"name" : "symbol", "primary" : false, "transform" : "bytesToString", "inputs" : ["symbol"]},
+				"owner" : {"name" : "owner", "primary" : false, "transform" : "checksum"},
+				"ownerHex" : {"name" : "ownerhex", "primary" : false, "transform" : "hex", "inputs" : ["owner"]},
+				"amount" : {"name" : "amount", "primary" : false, "transform" : "decimals", "decimals" : 18},
+				"status" : {"name" : "status", "primary" : false, "transform" : "enum", "labels" : ["Active", "Paused", "Closed"]},
+				"tokenKey" : {"name" : "tokenkey", "primary" : true, "transform" : "concat", "inputs" : ["symbol", "owner", "height"], "separator" : ":"}
+			}
+		}
+	]`
+
+	return derivedColumnsJSONConfFile
+}
+
+// ExceptionPolicyJSONConfFile sets a json file with an event definition for each exception policy
+// to be used in parser tests
+func ExceptionPolicyJSONConfFile(t *testing.T) string {
+	t.Helper()
+
+	exceptionPolicyJSONConfFile := `[
+		{
+			"TableName" : "Skipped",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{"indexed": false, "name": "key", "type": "uint256"}],
+				"name": "SkipEvent",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "key", "primary" : true}
+			}
+		},
+		{
+			"TableName" : "Flagged",
+			"OnException" : "column",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{"indexed": false, "name": "key", "type": "uint256"}],
+				"name": "ColumnEvent",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "key", "primary" : true}
+			}
+		},
+		{
+			"TableName" : "Routed",
+			"OnException" : "table",
+			"Event"  : {
+				"anonymous": false,
+				"inputs": [{"indexed": false, "name": "key", "type": "uint256"}],
+				"name": "TableEvent",
+				"type": "event"
+			},
+			"Columns"  : {
+				"key" : {"name" : "key", "primary" : true}
+			}
+		}
+	]`
+
+	return exceptionPolicyJSONConfFile
+}
+
 // MissingFieldsJSONConfFile sets a json file with missing fields to be used in parser tests
 func MissingFieldsJSONConfFile(t *testing.T) string {
 	t.Helper()
@@ -107,7 +622,7 @@ func UnknownTypeJSONConfFile(t *testing.T) string {
 	unknownTypeJSONConfFile := `[
 		{
 			"TableName" : "UserAccounts",
-			"Filter" : "LOG0 = 'UserAccounts'",
+			"Filter" : "LOG1 = 'UserAccounts'",
 			"Event"  : {
 				"anonymous": false,
 				"inputs": [{
@@ -133,7 +648,7 @@ func UnknownTypeJSONConfFile(t *testing.T) string {
 		},
 		{
 			"TableName" : "EventTest",
-			"Filter" : "LOG0 = 'EventTest'",
+			"Filter" : "LOG1 = 'EventTest'",
 			"Event"  : {
 				"anonymous": false,
 				"inputs": [{
@@ -1,15 +1,27 @@
 package types
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/go-ozzo/ozzo-validation"
 )
 
 // EventDefinition struct (table name where to persist filtered events and it structure)
+// by default logs are matched by the keccak256 hash of the event signature in Topics[0],
+// EventNameTopic matches them by the event name written as text in Topics[1] instead,
+// Addresses restricts logs to the ones emitted by given contract addresses
+// (addresses given as $jobName are read from the burrow deploy output DeployFile),
+// OnException sets how events of executions with exceptions are stored (skipped by default)
 type EventDefinition struct {
-	TableName string                 `json:"TableName"`
-	Filter    string                 `json:"Filter"`
-	Event     Event                  `json:"Event"`
-	Columns   map[string]EventColumn `json:"Columns"`
+	TableName      string                 `json:"TableName"`
+	Filter         string                 `json:"Filter,omitempty"`
+	EventNameTopic bool                   `json:"EventNameTopic,omitempty"`
+	Addresses      []string               `json:"Addresses,omitempty"`
+	DeployFile     string                 `json:"DeployFile,omitempty"`
+	OnException    string                 `json:"OnException,omitempty"`
+	Event          Event                  `json:"Event"`
+	Columns        map[string]EventColumn `json:"Columns"`
 }
 
 // Validate checks the structure of an EventDefinition
@@ -18,6 +30,7 @@ func (evDef EventDefinition) Validate() error {
 		validation.Field(&evDef.TableName, validation.Required, validation.Length(1, 60)),
 		validation.Field(&evDef.Event, validation.Required),
 		validation.Field(&evDef.Columns, validation.Required, validation.Length(1, 0)),
+		validation.Field(&evDef.OnException, validation.In(ExceptionPolicySkip, ExceptionPolicyColumn, ExceptionPolicyTable)),
 	)
 }
 
@@ -37,11 +50,23 @@ func (ev Event) Validate() error {
 	)
 }
 
-// EventInput struct (each event input)
+// Signature returns the canonical event signature, i.e. Name(type1,type2,...)
+func (ev Event) Signature() string {
+	inputTypes := make([]string, len(ev.Inputs))
+
+	for i, input := range ev.Inputs {
+		inputTypes[i] = input.CanonicalType()
+	}
+
+	return fmt.Sprintf("%s(%s)", ev.Name, strings.Join(inputTypes, ","))
+}
+
+// EventInput struct (each event input, tuple inputs hold their Components)
 type EventInput struct {
-	Indexed bool   `json:"indexed"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
+	Indexed    bool         `json:"indexed"`
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	Components []EventInput `json:"components,omitempty"`
 }
 
 // Validate checks the structure of an EventInput
@@ -49,18 +74,77 @@ func (evInput EventInput) Validate() error {
 	return validation.ValidateStruct(&evInput,
 		validation.Field(&evInput.Name, validation.Required, validation.Length(1, 0)),
 		validation.Field(&evInput.Type, validation.Required, validation.By(IsValidEventInputType)),
+		validation.Field(&evInput.Components, validation.By(evInput.isValidComponents)),
 	)
 }
 
+// isValidComponents checks tuple inputs have components
+func (evInput EventInput) isValidComponents(value interface{}) error {
+	if _, err := evInput.ParseType(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseType parses the event input type
+func (evInput EventInput) ParseType() (EventInputType, error) {
+	return ParseEventInputType(evInput.Type, evInput.Components)
+}
+
+// CanonicalType returns the event input type used to compute event signatures
+func (evInput EventInput) CanonicalType() string {
+	evType, err := evInput.ParseType()
+	if err != nil {
+		return strings.ToLower(evInput.Type)
+	}
+	return evType.Canonical()
+}
+
+// defined event column transforms
+const (
+	ColumnTransformBytesToString = "bytesToString"
+	ColumnTransformDecimals      = "decimals"
+	ColumnTransformEnum          = "enum"
+	ColumnTransformHex           = "hex"
+	ColumnTransformChecksum      = "checksum"
+	ColumnTransformConcat        = "concat"
+)
+
+// defined policies for events of executions with exceptions
+const (
+	ExceptionPolicySkip   = "skip"
+	ExceptionPolicyColumn = "column"
+	ExceptionPolicyTable  = "table"
+)
+
 // EventColumn struct (table column definition)
+// ChildTable expands an array input into a child table with a row for each element,
+// keyed by the parent row primary key and the element ordinal,
+// Transform derives the column value from Inputs (defaults to the input named as the column key),
+// using Decimals, Labels or Separator depending on the transform
 type EventColumn struct {
-	Name    string `json:"name"`
-	Primary bool   `json:"primary"`
+	Name       string   `json:"name"`
+	Primary    bool     `json:"primary"`
+	ChildTable bool     `json:"childTable,omitempty"`
+	Transform  string   `json:"transform,omitempty"`
+	Inputs     []string `json:"inputs,omitempty"`
+	Decimals   int      `json:"decimals,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Separator  string   `json:"separator,omitempty"`
 }
 
 // Validate checks the structure of an EventColumn
 func (evColumn EventColumn) Validate() error {
 	return validation.ValidateStruct(&evColumn,
 		validation.Field(&evColumn.Name, validation.Required, validation.Length(1, 60)),
+		validation.Field(&evColumn.Transform, validation.In(
+			ColumnTransformBytesToString,
+			ColumnTransformDecimals,
+			ColumnTransformEnum,
+			ColumnTransformHex,
+			ColumnTransformChecksum,
+			ColumnTransformConcat,
+		)),
+		validation.Field(&evColumn.Decimals, validation.Min(0), validation.Max(78)),
 	)
 }
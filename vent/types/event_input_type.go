@@ -2,6 +2,9 @@ package types
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -13,21 +16,202 @@ const (
 	EventInputTypeBytes   = "bytes"
 	EventInputTypeBool    = "bool"
 	EventInputTypeString  = "string"
+	EventInputTypeTuple   = "tuple"
 )
 
+var (
+	arrayTypeRegexp = regexp.MustCompile(`^(.*)\[([0-9]*)\]$`)
+	sizedTypeRegexp = regexp.MustCompile(`^(int|uint|bytes)([0-9]+)$`)
+)
+
+// EventInputType struct (parsed event input type)
+// Size is the number of bits of intN/uintN types and the number of bytes of bytesN types
+// (0 for dynamic bytes), Dimensions holds array lengths as written in the type,
+// i.e. from the innermost to the outermost one (0 for dynamic arrays)
+type EventInputType struct {
+	Base       string
+	Size       int
+	Dimensions []int
+	Components []EventInput
+}
+
+// ParseEventInputType parses an event input type (with its tuple components, if any)
+func ParseEventInputType(inputType string, components []EventInput) (EventInputType, error) {
+	var dimensions []int
+
+	val := strings.ToLower(strings.TrimSpace(inputType))
+
+	// array suffixes
+	for m := arrayTypeRegexp.FindStringSubmatch(val); m != nil; m = arrayTypeRegexp.FindStringSubmatch(val) {
+		length := 0
+		if m[2] != "" {
+			n, err := strconv.Atoi(m[2])
+			if err != nil || n == 0 {
+				return EventInputType{}, fmt.Errorf("invalid array length in type %s", inputType)
+			}
+			length = n
+		}
+		dimensions = append([]int{length}, dimensions...)
+		val = m[1]
+	}
+
+	evType := EventInputType{Base: val, Dimensions: dimensions}
+
+	if m := sizedTypeRegexp.FindStringSubmatch(val); m != nil {
+		size, err := strconv.Atoi(m[2])
+		if err != nil {
+			return EventInputType{}, fmt.Errorf("invalid size in type %s", inputType)
+		}
+
+		switch m[1] {
+		case EventInputTypeBytes:
+			if size < 1 || size > 32 {
+				return EventInputType{}, fmt.Errorf("invalid size in type %s", inputType)
+			}
+		default:
+			if size < 8 || size > 256 || size%8 != 0 {
+				return EventInputType{}, fmt.Errorf("invalid size in type %s", inputType)
+			}
+		}
+
+		evType.Base = m[1]
+		evType.Size = size
+		return evType, nil
+	}
+
+	switch val {
+	case EventInputTypeInt, EventInputTypeUInt:
+		evType.Size = 256
+	case EventInputTypeAddress, EventInputTypeBytes, EventInputTypeBool, EventInputTypeString:
+	case EventInputTypeTuple:
+		if len(components) == 0 {
+			return EventInputType{}, fmt.Errorf("tuple type %s without components", inputType)
+		}
+		evType.Components = components
+	default:
+		return EventInputType{}, fmt.Errorf("unknown type %s", inputType)
+	}
+
+	return evType, nil
+}
+
 // IsValidEventInputType checks if the event input type is a valid one
 func IsValidEventInputType(value interface{}) error {
 	input, _ := value.(string)
-	val := strings.ToLower(input)
 
-	if val == EventInputTypeInt ||
-		val == EventInputTypeUInt ||
-		val == EventInputTypeAddress ||
-		val == EventInputTypeBytes ||
-		val == EventInputTypeBool ||
-		val == EventInputTypeString {
-		return nil
+	// tuple components are checked by the event input
+	if _, err := ParseEventInputType(input, []EventInput{{}}); err != nil {
+		return errors.New("invalid event input type")
+	}
+
+	return nil
+}
+
+// IsArray returns true for fixed and dynamic array types
+func (evType EventInputType) IsArray() bool {
+	return len(evType.Dimensions) > 0
+}
+
+// IsTuple returns true for tuple (struct) types which are not arrays
+func (evType EventInputType) IsTuple() bool {
+	return evType.Base == EventInputTypeTuple && !evType.IsArray()
+}
+
+// Elem returns the type of the elements of an array type
+func (evType EventInputType) Elem() EventInputType {
+	elem := evType
+	elem.Dimensions = evType.Dimensions[:len(evType.Dimensions)-1]
+	return elem
+}
+
+// ArrayLength returns the length of a fixed array type, 0 for dynamic arrays
+func (evType EventInputType) ArrayLength() int {
+	return evType.Dimensions[len(evType.Dimensions)-1]
+}
+
+// IsDynamic returns true if values of the type are ABI encoded out of the head,
+// i.e. dynamic bytes, strings, dynamic arrays and types containing them
+func (evType EventInputType) IsDynamic() bool {
+	if evType.IsArray() {
+		return evType.ArrayLength() == 0 || evType.Elem().IsDynamic()
+	}
+
+	switch evType.Base {
+	case EventInputTypeString:
+		return true
+	case EventInputTypeBytes:
+		return evType.Size == 0
+	case EventInputTypeTuple:
+		for _, component := range evType.Components {
+			componentType, err := component.ParseType()
+			if err != nil || componentType.IsDynamic() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsValueType returns true for types stored in a single word,
+// the ones written as is in log topics when indexed (others are hashed)
+func (evType EventInputType) IsValueType() bool {
+	return !evType.IsArray() && !evType.IsTuple() && !evType.IsDynamic()
+}
+
+// HeadSize returns the number of bytes taken by a value of the type in the head of ABI encoded data
+func (evType EventInputType) HeadSize() int {
+	if evType.IsDynamic() {
+		return 32
+	}
+
+	if evType.IsArray() {
+		return evType.ArrayLength() * evType.Elem().HeadSize()
+	}
+
+	if evType.IsTuple() {
+		size := 0
+		for _, component := range evType.Components {
+			componentType, _ := component.ParseType()
+			size += componentType.HeadSize()
+		}
+		return size
+	}
+
+	return 32
+}
+
+// Canonical returns the type used to compute event signatures,
+// where int and uint are aliases for int256 and uint256
+// and tuples are written as the list of their components types
+func (evType EventInputType) Canonical() string {
+	var base string
+
+	switch evType.Base {
+	case EventInputTypeInt, EventInputTypeUInt:
+		base = fmt.Sprintf("%s%d", evType.Base, evType.Size)
+	case EventInputTypeBytes:
+		base = evType.Base
+		if evType.Size > 0 {
+			base = fmt.Sprintf("%s%d", evType.Base, evType.Size)
+		}
+	case EventInputTypeTuple:
+		componentTypes := make([]string, len(evType.Components))
+		for i, component := range evType.Components {
+			componentTypes[i] = component.CanonicalType()
+		}
+		base = fmt.Sprintf("(%s)", strings.Join(componentTypes, ","))
+	default:
+		base = evType.Base
+	}
+
+	for _, length := range evType.Dimensions {
+		if length > 0 {
+			base += fmt.Sprintf("[%d]", length)
+		} else {
+			base += "[]"
+		}
 	}
 
-	return errors.New("invalid event input type")
+	return base
 }
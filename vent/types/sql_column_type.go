@@ -12,9 +12,15 @@ const (
 	SQLColumnTypeText
 	SQLColumnTypeVarchar
 	SQLColumnTypeTimeStamp
+	SQLColumnTypeBigInt
+	SQLColumnTypeNumeric
+	SQLColumnTypeJSON
 )
 
 // IsNumeric determines if an sqlColumnType is numeric
 func (sqlColumnType SQLColumnType) IsNumeric() bool {
-	return sqlColumnType == SQLColumnTypeInt || sqlColumnType == SQLColumnTypeSerial
+	return sqlColumnType == SQLColumnTypeInt ||
+		sqlColumnType == SQLColumnTypeSerial ||
+		sqlColumnType == SQLColumnTypeBigInt ||
+		sqlColumnType == SQLColumnTypeNumeric
 }